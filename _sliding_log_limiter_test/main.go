@@ -1,9 +1,9 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter/httplimiter"
 	limiter "github.com/jiaxwu/limiter/redis"
 	"net/http"
 	"time"
@@ -13,19 +13,18 @@ func main() {
 	client := redis.NewClient(&redis.Options{
 		Addr: "127.0.0.1:6379",
 	})
-	l, _ := limiter.NewSlidingLogLimiter(client, time.Second,
+	l, _ := limiter.NewSlidingLogLimiter(client, time.Second, []*limiter.SlidingLogLimiterStrategy{
 		limiter.NewSlidingLogLimiterStrategy(10, time.Second*30),
-		limiter.NewSlidingLogLimiterStrategy(15, time.Minute))
-	count := 0
-	http.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
-		err := l.TryAcquire(context.Background(), "test")
-		if err == nil {
-			w.Write([]byte("请求成功" + time.Now().String()))
-			count++
-			fmt.Println(count)
-		} else {
-			w.Write([]byte(err.Error() + time.Now().String()))
-		}
+		limiter.NewSlidingLogLimiterStrategy(15, time.Minute),
+	})
+	middleware := httplimiter.NewMiddleware(l, func(r *http.Request) (string, error) {
+		return "test", nil
 	})
+	count := 0
+	http.Handle("/test", middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("请求成功" + time.Now().String()))
+		count++
+		fmt.Println(count)
+	})))
 	http.ListenAndServe("127.0.0.1:8080", nil)
 }
@@ -0,0 +1,158 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// 窗口开始时间最少的位数
+const atomicFixedWindowMinStartBits = 40
+
+// AtomicFixedWindowLimiter 无锁固定窗口限流器，语义同FixedWindowLimiter
+// 窗口开始时间和计数器打包到一个uint64中，通过CAS原子地更新：
+// 低位是计数器，位数刚好能表示窗口请求上限，高位是窗口开始时间（距离创建时间的微秒数）。
+// 窗口开始时间精度为微秒，按剩余的位数取模保存，每2^(64-计数器位数)微秒回绕一次，
+// 窗口请求上限小于2^20时约200天，窗口请求上限越大回绕越快，因此窗口请求上限不能超过2^24-1（回绕周期约12.7天），
+// 窗口时间大小不能超过回绕周期。限流器闲置接近回绕周期的整数倍后，已经失效的窗口可能被误判为当前窗口，
+// 最多拒绝一个窗口时间的请求
+type AtomicFixedWindowLimiter struct {
+	state       uint64        // 窗口开始时间<<counterBits | 计数器，放在开头保证32位平台上原子操作的对齐
+	limit       int           // 窗口请求上限
+	window      time.Duration // 窗口时间大小
+	counterBits int           // 计数器的位数
+	base        time.Time     // 创建时间，窗口开始时间相对于它计算
+	clock       Clock         // 时钟
+}
+
+func NewAtomicFixedWindowLimiter(limit int, window time.Duration, opts ...Option) (*AtomicFixedWindowLimiter, error) {
+	// 窗口开始时间至少保留40位，避免回绕周期太短
+	counterBits := bits.Len(uint(maxInt(0, limit)))
+	if counterBits > 64-atomicFixedWindowMinStartBits {
+		return nil, errors.New("the limit must be less than 2^24")
+	}
+	if window > time.Duration(1)<<atomicFixedWindowMinStartBits*time.Microsecond {
+		return nil, errors.New("the window must not exceed 2^40 microseconds")
+	}
+
+	o := newOptions(opts)
+	return &AtomicFixedWindowLimiter{
+		limit:       limit,
+		window:      window,
+		counterBits: counterBits,
+		base:        o.clock.Now(),
+		clock:       o.clock,
+	}, nil
+}
+
+// TryAcquire 尝试获取许可，内存限流器只保护单个资源，因此忽略resource
+func (l *AtomicFixedWindowLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过窗口请求上限
+func (l *AtomicFixedWindowLimiter) TryAcquireN(_ context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *AtomicFixedWindowLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *AtomicFixedWindowLimiter) WaitN(ctx context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	return Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(n)
+	})
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过窗口请求上限
+func (l *AtomicFixedWindowLimiter) Allow(_ context.Context, _ string, n int) (*Result, error) {
+	if err := checkPermits(n, l.limit); err != nil {
+		return nil, err
+	}
+	result := l.acquire(n)
+	return &result, nil
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，返回实际获取的许可数量，没有剩余许可时返回0
+func (l *AtomicFixedWindowLimiter) TryAcquireUpTo(_ context.Context, _ string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	for {
+		old := atomic.LoadUint64(&l.state)
+		start, counter := l.refresh(old, l.clock.Now().Sub(l.base))
+		// 获取剩余的许可，但不超过n
+		granted := maxInt(0, minInt(n, l.limit-counter))
+		if l.compareAndSwap(old, start, counter+granted) {
+			return granted, nil
+		}
+	}
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *AtomicFixedWindowLimiter) tryAcquire(n int) (time.Duration, error) {
+	if result := l.acquire(n); !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *AtomicFixedWindowLimiter) acquire(n int) Result {
+	for {
+		old := atomic.LoadUint64(&l.state)
+		// 获取当前时间
+		now := l.clock.Now().Sub(l.base)
+		start, counter := l.refresh(old, now)
+		// 当前窗口失效的时间
+		resetAfter := l.window - (now - start) + time.Nanosecond
+		result := Result{Limit: l.limit}
+		// 若超过窗口请求上限，请求失败，需要等待到当前窗口失效
+		if counter+n > l.limit {
+			result.RetryAfter = resetAfter
+		} else {
+			// 若没超过窗口请求上限，计数器+n，请求成功
+			counter += n
+			result.Allowed = true
+		}
+		if !l.compareAndSwap(old, start, counter) {
+			continue
+		}
+		result.Remaining = l.limit - counter
+		if counter > 0 {
+			result.ResetAfter = resetAfter
+		}
+		return result
+	}
+}
+
+// 解包状态，如果当前窗口失效，计数器清0，开启新的窗口，返回窗口开始时间和计数器
+func (l *AtomicFixedWindowLimiter) refresh(state uint64, now time.Duration) (time.Duration, int) {
+	mask := uint64(1)<<(64-l.counterBits) - 1
+	nowMicros := uint64(now / time.Microsecond)
+	// 窗口开始时间按位数取模保存，通过和当前时间的差值还原
+	elapsedMicros := (nowMicros - state>>l.counterBits) & mask
+	start := time.Duration(nowMicros-elapsedMicros) * time.Microsecond
+	if now-start > l.window {
+		return time.Duration(nowMicros) * time.Microsecond, 0
+	}
+	return start, int(state & (uint64(1)<<l.counterBits - 1))
+}
+
+// 状态没有变化时直接返回成功，否则打包状态并CAS
+func (l *AtomicFixedWindowLimiter) compareAndSwap(old uint64, start time.Duration, counter int) bool {
+	state := uint64(start/time.Microsecond)<<l.counterBits | uint64(counter)
+	return state == old || atomic.CompareAndSwapUint64(&l.state, old, state)
+}
@@ -0,0 +1,115 @@
+package limiter
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestAtomicFixedWindowLimiterAlignment(t *testing.T) {
+	// 32位平台上只保证分配的结构体开头是8字节对齐的，因此原子操作的64位字段必须放在开头
+	if offset := unsafe.Offsetof(AtomicFixedWindowLimiter{}.state); offset != 0 {
+		t.Errorf("Offsetof(state) = %v, want 0", offset)
+	}
+}
+
+func TestAtomicFixedWindowLimiter(t *testing.T) {
+	// 和互斥锁版本执行相同的随机操作，时间按微秒前进时结果应该完全一致
+	clock := NewManualClock(time.Unix(0, 0))
+	want := NewFixedWindowLimiter(10, time.Second, WithClock(clock))
+	got, _ := NewAtomicFixedWindowLimiter(10, time.Second, WithClock(clock))
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 10000; i++ {
+		clock.Advance(time.Duration(r.Intn(300000)) * time.Microsecond)
+		n := r.Intn(10) + 1
+		if r.Intn(4) == 0 {
+			wantN, _ := want.TryAcquireUpTo(context.Background(), "test", n)
+			gotN, _ := got.TryAcquireUpTo(context.Background(), "test", n)
+			if gotN != wantN {
+				t.Fatalf("%d TryAcquireUpTo(%d) = %v, want %v", i, n, gotN, wantN)
+			}
+			continue
+		}
+		wantResult, _ := want.Allow(context.Background(), "test", n)
+		gotResult, _ := got.Allow(context.Background(), "test", n)
+		if *gotResult != *wantResult {
+			t.Fatalf("%d Allow(%d) = %+v, want %+v", i, n, *gotResult, *wantResult)
+		}
+	}
+}
+
+func TestAtomicFixedWindowLimiterConcurrent(t *testing.T) {
+	l, _ := NewAtomicFixedWindowLimiter(1000, time.Hour)
+	// 并发获取，成功的数量刚好等于窗口请求上限
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	successCount := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if granted, _ := l.TryAcquireUpTo(context.Background(), "test", 2); granted > 0 {
+					mutex.Lock()
+					successCount += granted
+					mutex.Unlock()
+				}
+				if l.TryAcquire(context.Background(), "test") == nil {
+					mutex.Lock()
+					successCount++
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if successCount != 1000 {
+		t.Errorf("successCount = %v, want %v", successCount, 1000)
+	}
+}
+
+func TestNewAtomicFixedWindowLimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		window  time.Duration
+		wantErr bool
+	}{
+		{name: "max_limit", limit: 1<<24 - 1, window: time.Hour},
+		// 窗口开始时间的位数太少，回绕周期太短
+		{name: "limit_too_large", limit: 1 << 24, window: time.Hour, wantErr: true},
+		{name: "max_window", limit: 10, window: time.Duration(1) << 40 * time.Microsecond},
+		{name: "window_too_large", limit: 10, window: time.Duration(1)<<40*time.Microsecond + 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAtomicFixedWindowLimiter(tt.limit, tt.window); (err != nil) != tt.wantErr {
+				t.Errorf("NewAtomicFixedWindowLimiter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func BenchmarkAtomicFixedWindowLimiter(b *testing.B) {
+	atomicFixedWindowLimiter, _ := NewAtomicFixedWindowLimiter(1000, time.Nanosecond)
+	tests := []struct {
+		name    string
+		limiter Limiter
+	}{
+		{name: "mutex", limiter: NewFixedWindowLimiter(1000, time.Nanosecond)},
+		{name: "atomic", limiter: atomicFixedWindowLimiter},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					tt.limiter.TryAcquire(context.Background(), "test")
+				}
+			})
+		})
+	}
+}
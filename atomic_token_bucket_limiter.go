@@ -0,0 +1,185 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// AtomicTokenBucketLimiter 无锁令牌桶限流器，语义同TokenBucketLimiter
+// 状态只有令牌数量为0的时间emptyTime，令牌数量 = min(容量, 当前时间-emptyTime)，时间的单位是发放一个令牌的时间，
+// 获取n个令牌相当于把emptyTime往后移动n，因此可以通过CAS原子地更新
+type AtomicTokenBucketLimiter struct {
+	emptyTime uint64    // 令牌数量为0的时间（距离创建时间的秒数*速率，float64的位），预留许可时可能晚于当前时间，放在开头保证32位平台上原子操作的对齐
+	capacity  int       // 容量
+	rate      float64   // 发放令牌速率/秒
+	base      time.Time // 创建时间
+	clock     Clock     // 时钟
+}
+
+func NewAtomicTokenBucketLimiter(capacity int, rate float64, opts ...Option) *AtomicTokenBucketLimiter {
+	o := newOptions(opts)
+	// 初始没有令牌，因此emptyTime是0
+	return &AtomicTokenBucketLimiter{
+		capacity: capacity,
+		rate:     rate,
+		base:     o.clock.Now(),
+		clock:    o.clock,
+	}
+}
+
+// TryAcquire 尝试获取许可，内存限流器只保护单个资源，因此忽略resource
+func (l *AtomicTokenBucketLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过容量
+func (l *AtomicTokenBucketLimiter) TryAcquireN(_ context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *AtomicTokenBucketLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *AtomicTokenBucketLimiter) WaitN(ctx context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return err
+	}
+	return Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(n)
+	})
+}
+
+// Reserve 预留一个许可，令牌不足时预支未来的令牌，调用方需要等待Reservation.Delay()之后再执行操作
+func (l *AtomicTokenBucketLimiter) Reserve(ctx context.Context, resource string) *Reservation {
+	return l.ReserveN(ctx, resource, 1)
+}
+
+// ReserveN 预留n个许可，其他同Reserve
+func (l *AtomicTokenBucketLimiter) ReserveN(_ context.Context, _ string, n int) *Reservation {
+	// 许可数量不合法或者超过容量，永远无法满足
+	if checkPermits(n, l.capacity) != nil {
+		return &Reservation{}
+	}
+	for {
+		old := atomic.LoadUint64(&l.emptyTime)
+		now := l.clock.Now()
+		current := l.now(now)
+		// 预支n个令牌
+		emptyTime := l.refill(math.Float64frombits(old), current) + float64(n)
+		if !atomic.CompareAndSwapUint64(&l.emptyTime, old, math.Float64bits(emptyTime)) {
+			continue
+		}
+		return &Reservation{
+			ok:        true,
+			timeToAct: now.Add(l.waitTokens(current-emptyTime, 0)),
+			clock:     l.clock,
+			cancel: func() {
+				l.cancel(n)
+			},
+		}
+	}
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过容量
+func (l *AtomicTokenBucketLimiter) Allow(_ context.Context, _ string, n int) (*Result, error) {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return nil, err
+	}
+	result := l.acquire(n)
+	return &result, nil
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，返回实际获取的许可数量，没有令牌时返回0
+func (l *AtomicTokenBucketLimiter) TryAcquireUpTo(_ context.Context, _ string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	for {
+		old := atomic.LoadUint64(&l.emptyTime)
+		now := l.now(l.clock.Now())
+		emptyTime := l.refill(math.Float64frombits(old), now)
+		// 获取剩余的令牌，但不超过n，预留许可时令牌可能为负数
+		granted := maxInt(0, minInt(n, int(math.Floor(now-emptyTime))))
+		if granted == 0 {
+			return 0, nil
+		}
+		if atomic.CompareAndSwapUint64(&l.emptyTime, old, math.Float64bits(emptyTime+float64(granted))) {
+			return granted, nil
+		}
+	}
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *AtomicTokenBucketLimiter) tryAcquire(n int) (time.Duration, error) {
+	if result := l.acquire(n); !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *AtomicTokenBucketLimiter) acquire(n int) Result {
+	for {
+		old := atomic.LoadUint64(&l.emptyTime)
+		// 尝试发放令牌
+		now := l.now(l.clock.Now())
+		emptyTime := l.refill(math.Float64frombits(old), now)
+		tokens := now - emptyTime
+
+		result := Result{Limit: l.capacity}
+		// 如果令牌不足，请求失败，需要等待到有足够的令牌
+		if tokens < float64(n) {
+			result.RetryAfter = l.waitTokens(tokens, n)
+		} else {
+			// 如果令牌足够，emptyTime+n，请求成功
+			if !atomic.CompareAndSwapUint64(&l.emptyTime, old, math.Float64bits(emptyTime+float64(n))) {
+				continue
+			}
+			tokens -= float64(n)
+			result.Allowed = true
+		}
+		// 预留许可时令牌可能为负数
+		result.Remaining = maxInt(0, int(math.Floor(tokens)))
+		result.ResetAfter = l.waitTokens(tokens, l.capacity)
+		return result
+	}
+}
+
+// 归还n个预留的令牌，但不能超过容量
+func (l *AtomicTokenBucketLimiter) cancel(n int) {
+	for {
+		old := atomic.LoadUint64(&l.emptyTime)
+		now := l.now(l.clock.Now())
+		emptyTime := math.Max(l.refill(math.Float64frombits(old), now)-float64(n), now-float64(l.capacity))
+		if atomic.CompareAndSwapUint64(&l.emptyTime, old, math.Float64bits(emptyTime)) {
+			return
+		}
+	}
+}
+
+// 当前时间，单位是发放一个令牌的时间
+func (l *AtomicTokenBucketLimiter) now(now time.Time) float64 {
+	return now.Sub(l.base).Seconds() * l.rate
+}
+
+// 发放令牌，令牌数量不能超过容量，返回发放之后的emptyTime
+func (l *AtomicTokenBucketLimiter) refill(emptyTime, now float64) float64 {
+	return math.Max(emptyTime, now-float64(l.capacity))
+}
+
+// 令牌数量从tokens达到n需要等待的时间
+func (l *AtomicTokenBucketLimiter) waitTokens(tokens float64, n int) time.Duration {
+	if tokens >= float64(n) {
+		return 0
+	}
+	return secondsToDuration((float64(n) - tokens) / l.rate)
+}
@@ -0,0 +1,106 @@
+package limiter
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestAtomicTokenBucketLimiterAlignment(t *testing.T) {
+	// 32位平台上只保证分配的结构体开头是8字节对齐的，因此原子操作的64位字段必须放在开头
+	if offset := unsafe.Offsetof(AtomicTokenBucketLimiter{}.emptyTime); offset != 0 {
+		t.Errorf("Offsetof(emptyTime) = %v, want 0", offset)
+	}
+}
+
+func TestAtomicTokenBucketLimiter(t *testing.T) {
+	// 和互斥锁版本执行相同的随机操作，结果应该完全一致
+	// 速率为4时令牌数量都是0.25的倍数，浮点数没有误差
+	clock := NewManualClock(time.Unix(0, 0))
+	want := NewTokenBucketLimiter(10, 4, WithClock(clock))
+	got := NewAtomicTokenBucketLimiter(10, 4, WithClock(clock))
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 10000; i++ {
+		clock.Advance(time.Duration(r.Intn(9)) * time.Second / 8)
+		n := r.Intn(10) + 1
+		switch r.Intn(4) {
+		case 0:
+			wantN, _ := want.TryAcquireUpTo(context.Background(), "test", n)
+			gotN, _ := got.TryAcquireUpTo(context.Background(), "test", n)
+			if gotN != wantN {
+				t.Fatalf("%d TryAcquireUpTo(%d) = %v, want %v", i, n, gotN, wantN)
+			}
+		case 1:
+			wantR := want.ReserveN(context.Background(), "test", n)
+			gotR := got.ReserveN(context.Background(), "test", n)
+			if gotR.Delay() != wantR.Delay() {
+				t.Fatalf("%d ReserveN(%d).Delay() = %v, want %v", i, n, gotR.Delay(), wantR.Delay())
+			}
+			if r.Intn(2) == 0 {
+				wantR.Cancel()
+				gotR.Cancel()
+			}
+		default:
+			wantResult, _ := want.Allow(context.Background(), "test", n)
+			gotResult, _ := got.Allow(context.Background(), "test", n)
+			if *gotResult != *wantResult {
+				t.Fatalf("%d Allow(%d) = %+v, want %+v", i, n, *gotResult, *wantResult)
+			}
+		}
+	}
+}
+
+func TestAtomicTokenBucketLimiterConcurrent(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewAtomicTokenBucketLimiter(1000, 1000, WithClock(clock))
+	clock.Advance(time.Second)
+	// 并发获取，成功的数量刚好等于令牌数量
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	successCount := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if granted, _ := l.TryAcquireUpTo(context.Background(), "test", 2); granted > 0 {
+					mutex.Lock()
+					successCount += granted
+					mutex.Unlock()
+				}
+				if l.TryAcquire(context.Background(), "test") == nil {
+					mutex.Lock()
+					successCount++
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if successCount != 1000 {
+		t.Errorf("successCount = %v, want %v", successCount, 1000)
+	}
+}
+
+func BenchmarkAtomicTokenBucketLimiter(b *testing.B) {
+	tests := []struct {
+		name    string
+		limiter Limiter
+	}{
+		{name: "mutex", limiter: NewTokenBucketLimiter(1000, 1e9)},
+		{name: "atomic", limiter: NewAtomicTokenBucketLimiter(1000, 1e9)},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					tt.limiter.TryAcquire(context.Background(), "test")
+				}
+			})
+		})
+	}
+}
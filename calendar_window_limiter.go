@@ -0,0 +1,182 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CalendarUnit 日历窗口的单位，窗口按时区的日历边界对齐，例如每天的零点和每月的一号
+type CalendarUnit int
+
+const (
+	CalendarMinute CalendarUnit = iota // 分钟
+	CalendarHour                       // 小时
+	CalendarDay                        // 天，从零点开始
+	CalendarWeek                       // ISO周，从周一零点开始
+	CalendarMonth                      // 月，从一号零点开始
+)
+
+// ErrInvalidCalendarUnit 日历窗口的单位无效
+var ErrInvalidCalendarUnit = errors.New("invalid calendar unit")
+
+// Valid 是否是有效的日历窗口单位
+func (u CalendarUnit) Valid() bool {
+	return u >= CalendarMinute && u <= CalendarMonth
+}
+
+// Window 返回t所在的窗口的开始时间和结束时间，窗口包含开始时间，不包含结束时间，loc为nil时使用UTC，u无效时panic
+// 分钟和小时窗口按经过的时间计算，因此夏令时切换时也是固定的长度，天、周和月窗口的长度随夏令时变化
+func (u CalendarUnit) Window(t time.Time, loc *time.Location) (time.Time, time.Time) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	switch u {
+	case CalendarMinute:
+		start := t.Add(-time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+		return start, start.Add(time.Minute)
+	case CalendarHour:
+		start := t.Add(-time.Duration(t.Minute())*time.Minute -
+			time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+		return start, start.Add(time.Hour)
+	case CalendarDay:
+		year, month, day := t.Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, loc), time.Date(year, month, day+1, 0, 0, 0, 0, loc)
+	case CalendarWeek:
+		year, month, day := t.Date()
+		// 周一是一周的第一天
+		day -= (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day, 0, 0, 0, 0, loc), time.Date(year, month, day+7, 0, 0, 0, 0, loc)
+	case CalendarMonth:
+		year, month, _ := t.Date()
+		return time.Date(year, month, 1, 0, 0, 0, 0, loc), time.Date(year, month+1, 1, 0, 0, 0, 0, loc)
+	default:
+		panic("limiter: unknown calendar unit")
+	}
+}
+
+// CalendarWindowLimiter 日历窗口限流器，和FixedWindowLimiter从窗口失效之后的第一个请求开始新的窗口不同，
+// 窗口按时区的日历边界对齐，所有资源在可预测的时间重置，适用于每天和每月配额
+type CalendarWindowLimiter struct {
+	limit    int            // 窗口请求上限
+	unit     CalendarUnit   // 窗口单位
+	location *time.Location // 计算日历边界的时区
+	counter  int            // 计数器
+	end      time.Time      // 当前窗口的结束时间
+	clock    Clock          // 时钟
+	mutex    sync.Mutex     // 避免并发问题
+}
+
+func NewCalendarWindowLimiter(limit int, unit CalendarUnit, location *time.Location,
+	opts ...Option) (*CalendarWindowLimiter, error) {
+	if !unit.Valid() {
+		return nil, ErrInvalidCalendarUnit
+	}
+
+	o := newOptions(opts)
+	l := &CalendarWindowLimiter{
+		limit:    limit,
+		unit:     unit,
+		location: location,
+		clock:    o.clock,
+	}
+	_, l.end = unit.Window(o.clock.Now(), location)
+	return l, nil
+}
+
+// TryAcquire 尝试获取许可，内存限流器只保护单个资源，因此忽略resource
+func (l *CalendarWindowLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过窗口请求上限
+func (l *CalendarWindowLimiter) TryAcquireN(_ context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *CalendarWindowLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *CalendarWindowLimiter) WaitN(ctx context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	return Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(n)
+	})
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过窗口请求上限
+func (l *CalendarWindowLimiter) Allow(_ context.Context, _ string, n int) (*Result, error) {
+	if err := checkPermits(n, l.limit); err != nil {
+		return nil, err
+	}
+	result := l.acquire(n)
+	return &result, nil
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，返回实际获取的许可数量，没有剩余许可时返回0
+func (l *CalendarWindowLimiter) TryAcquireUpTo(_ context.Context, _ string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.refresh(l.clock.Now())
+	// 获取剩余的许可，但不超过n
+	granted := maxInt(0, minInt(n, l.limit-l.counter))
+	l.counter += granted
+	return granted, nil
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *CalendarWindowLimiter) tryAcquire(n int) (time.Duration, error) {
+	if result := l.acquire(n); !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *CalendarWindowLimiter) acquire(n int) Result {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now()
+	l.refresh(now)
+	// 当前窗口结束的时间
+	resetAfter := l.end.Sub(now)
+	result := Result{Limit: l.limit}
+	// 若超过窗口请求上限，请求失败，需要等待到当前窗口结束
+	if l.counter+n > l.limit {
+		result.RetryAfter = resetAfter
+	} else {
+		// 若没超过窗口请求上限，计数器+n，请求成功
+		l.counter += n
+		result.Allowed = true
+	}
+	result.Remaining = l.limit - l.counter
+	if l.counter > 0 {
+		result.ResetAfter = resetAfter
+	}
+	return result
+}
+
+// 如果当前窗口结束，计数器清0，进入当前时间所在的窗口，时钟回拨时继续使用当前窗口
+func (l *CalendarWindowLimiter) refresh(now time.Time) {
+	if now.Before(l.end) {
+		return
+	}
+	l.counter = 0
+	_, l.end = l.unit.Window(now, l.location)
+}
@@ -0,0 +1,136 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCalendarUnitWindow(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*60*60)
+	india := time.FixedZone("IST", 5*60*60+30*60)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name      string
+		unit      CalendarUnit
+		loc       *time.Location
+		t         time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{
+			name:      "minute",
+			unit:      CalendarMinute,
+			t:         time.Date(2022, 3, 4, 5, 6, 7, 8, time.UTC),
+			wantStart: time.Date(2022, 3, 4, 5, 6, 0, 0, time.UTC),
+			wantEnd:   time.Date(2022, 3, 4, 5, 7, 0, 0, time.UTC),
+		},
+		{
+			// 半小时时区的小时从本地时间的整点开始
+			name:      "hour_half_hour_zone",
+			unit:      CalendarHour,
+			loc:       india,
+			t:         time.Date(2022, 3, 4, 5, 6, 7, 8, india),
+			wantStart: time.Date(2022, 3, 4, 5, 0, 0, 0, india),
+			wantEnd:   time.Date(2022, 3, 4, 6, 0, 0, 0, india),
+		},
+		{
+			// UTC的前一天是上海的当天
+			name:      "day_time_zone",
+			unit:      CalendarDay,
+			loc:       shanghai,
+			t:         time.Date(2022, 3, 3, 20, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2022, 3, 4, 0, 0, 0, 0, shanghai),
+			wantEnd:   time.Date(2022, 3, 5, 0, 0, 0, 0, shanghai),
+		},
+		{
+			// 夏令时开始的那天只有23小时
+			name:      "day_dst",
+			unit:      CalendarDay,
+			loc:       newYork,
+			t:         time.Date(2022, 3, 13, 12, 0, 0, 0, newYork),
+			wantStart: time.Date(2022, 3, 13, 0, 0, 0, 0, newYork),
+			wantEnd:   time.Date(2022, 3, 14, 0, 0, 0, 0, newYork),
+		},
+		{
+			// 2021-01-03是周日，属于从2020-12-28开始的ISO周
+			name:      "week_sunday",
+			unit:      CalendarWeek,
+			t:         time.Date(2021, 1, 3, 23, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2020, 12, 28, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "week_monday",
+			unit:      CalendarWeek,
+			t:         time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2021, 1, 11, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "month_december",
+			unit:      CalendarMonth,
+			loc:       shanghai,
+			t:         time.Date(2022, 12, 31, 23, 59, 59, 0, shanghai),
+			wantStart: time.Date(2022, 12, 1, 0, 0, 0, 0, shanghai),
+			wantEnd:   time.Date(2023, 1, 1, 0, 0, 0, 0, shanghai),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := tt.unit.Window(tt.t, tt.loc)
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("Window() = %v, %v, want %v, %v", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestCalendarWindowLimiter(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*60*60)
+	clock := NewManualClock(time.Date(2022, 3, 4, 23, 0, 0, 0, shanghai))
+	l, _ := NewCalendarWindowLimiter(2, CalendarDay, shanghai, WithClock(clock))
+	steps := []struct {
+		advance time.Duration // 请求之前经过的时间
+		want    Result
+	}{
+		{want: Result{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Hour}},
+		{advance: time.Minute, want: Result{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Hour - time.Minute}},
+		// 需要等待到零点
+		{advance: time.Minute,
+			want: Result{Limit: 2, Remaining: 0, ResetAfter: time.Hour - 2*time.Minute, RetryAfter: time.Hour - 2*time.Minute}},
+		// 零点重置，和第一个请求的时间无关
+		{advance: time.Hour - 2*time.Minute, want: Result{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: 24 * time.Hour}},
+	}
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		got, _ := l.Allow(context.Background(), "test", 1)
+		if *got != step.want {
+			t.Errorf("%d Allow() = %+v, want %+v", i, *got, step.want)
+		}
+	}
+}
+
+func TestNewCalendarWindowLimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		unit    CalendarUnit
+		wantErr error
+	}{
+		{name: "minute", unit: CalendarMinute},
+		{name: "month", unit: CalendarMonth},
+		{name: "negative", unit: -1, wantErr: ErrInvalidCalendarUnit},
+		{name: "out_of_range", unit: CalendarMonth + 1, wantErr: ErrInvalidCalendarUnit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCalendarWindowLimiter(10, tt.unit, time.UTC); !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewCalendarWindowLimiter() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 时钟，限流器通过它获取当前时间和等待，测试时可以注入ManualClock
+type Clock interface {
+	// Now 当前时间
+	Now() time.Time
+	// NewTimer 创建一个d之后触发的定时器
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer 定时器
+type Timer interface {
+	// C 定时器触发时会向该channel发送当前时间
+	C() <-chan time.Time
+	// Stop 停止定时器，若定时器已经触发或者已经停止返回false
+	Stop() bool
+}
+
+// SystemClock 系统时钟，限流器默认使用该时钟
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{timer: time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	timer *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// ManualClock 手动控制的时钟，只有调用Set或Advance时间才会流逝，用于确定性的测试
+type ManualClock struct {
+	now    time.Time      // 当前时间
+	timers []*manualTimer // 未触发的定时器
+	mutex  sync.Mutex     // 避免并发问题
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now 当前时间
+func (c *ManualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Set 设置当前时间，触发所有到期的定时器，时间不能倒流
+func (c *ManualClock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if now.Before(c.now) {
+		return
+	}
+	c.now = now
+	// 触发到期的定时器
+	timers := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.deadline.After(now) {
+			timers = append(timers, timer)
+		} else {
+			timer.c <- now
+		}
+	}
+	c.timers = timers
+}
+
+// Advance 时间前进d，触发所有到期的定时器
+func (c *ManualClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// NewTimer 创建一个d之后触发的定时器
+func (c *ManualClock) NewTimer(d time.Duration) Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	timer := &manualTimer{
+		clock:    c,
+		deadline: c.now.Add(d),
+		c:        make(chan time.Time, 1),
+	}
+	if d <= 0 {
+		timer.c <- c.now
+	} else {
+		c.timers = append(c.timers, timer)
+	}
+	return timer
+}
+
+// PendingTimers 未触发的定时器数量，测试时可以用来判断是否有协程在等待
+func (c *ManualClock) PendingTimers() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.timers)
+}
+
+type manualTimer struct {
+	clock    *ManualClock   // 所属时钟
+	deadline time.Time      // 触发时间
+	c        chan time.Time // 触发时发送当前时间
+}
+
+func (t *manualTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
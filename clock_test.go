@@ -0,0 +1,71 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewManualClock(start)
+	timer1 := clock.NewTimer(time.Second)
+	timer2 := clock.NewTimer(time.Second * 2)
+	timer3 := clock.NewTimer(time.Second * 3)
+	if !timer3.Stop() || timer3.Stop() {
+		t.Errorf("Stop() should only return true for pending timer")
+	}
+
+	clock.Advance(time.Second)
+	if got := clock.Now(); !got.Equal(start.Add(time.Second)) {
+		t.Errorf("Now() = %v, want %v", got, start.Add(time.Second))
+	}
+	select {
+	case <-timer1.C():
+	default:
+		t.Errorf("timer1 should fire")
+	}
+	select {
+	case <-timer2.C():
+		t.Errorf("timer2 should not fire")
+	default:
+	}
+	if got := clock.PendingTimers(); got != 1 {
+		t.Errorf("PendingTimers() = %v, want %v", got, 1)
+	}
+
+	// 时间不能倒流
+	clock.Set(start)
+	if got := clock.Now(); !got.Equal(start.Add(time.Second)) {
+		t.Errorf("Now() = %v, want %v", got, start.Add(time.Second))
+	}
+}
+
+func TestSlidingWindowLimiterWithManualClock(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	l, err := NewSlidingWindowLimiter(60, time.Second*5, time.Second, WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewSlidingWindowLimiter() error = %v", err)
+	}
+	acquire := func(n int) int {
+		successCount := 0
+		for i := 0; i < n; i++ {
+			if l.TryAcquire(context.Background(), "test") == nil {
+				successCount++
+			}
+		}
+		return successCount
+	}
+	if got := acquire(30); got != 30 {
+		t.Errorf("TryAcquire() got = %v, want %v", got, 30)
+	}
+	clock.Advance(time.Second * 2)
+	if got := acquire(60); got != 30 {
+		t.Errorf("TryAcquire() got = %v, want %v", got, 30)
+	}
+	// 第一个小窗口过期，释放30个请求
+	clock.Advance(time.Second * 3)
+	if got := acquire(60); got != 30 {
+		t.Errorf("TryAcquire() got = %v, want %v", got, 30)
+	}
+}
@@ -0,0 +1,136 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ExactSlidingLogLimiter 精确滑动日志限流器，记录每个许可的获取时间，适用于请求量小但需要精确限流的场景，例如重置密码和支付
+// 和SlidingLogLimiter不同，不按小窗口合并请求，因此任意窗口时间内通过的请求数都不会超过窗口请求上限
+// 获取时间保存在容量为窗口请求上限的环形数组中，因此内存占用和窗口请求上限成正比
+type ExactSlidingLogLimiter struct {
+	limit  int        // 窗口请求上限
+	window int64      // 窗口时间大小
+	logs   []int64    // 每个许可的获取时间，环形数组，从旧到新
+	head   int        // 最早的获取时间的下标
+	size   int        // 窗口内的许可数量
+	clock  Clock      // 时钟
+	mutex  sync.Mutex // 避免并发问题
+}
+
+func NewExactSlidingLogLimiter(limit int, window time.Duration, opts ...Option) *ExactSlidingLogLimiter {
+	return &ExactSlidingLogLimiter{
+		limit:  limit,
+		window: int64(window),
+		logs:   make([]int64, maxInt(0, limit)),
+		clock:  newOptions(opts).clock,
+	}
+}
+
+// TryAcquire 尝试获取许可，内存限流器只保护单个资源，因此忽略resource
+func (l *ExactSlidingLogLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过窗口请求上限
+func (l *ExactSlidingLogLimiter) TryAcquireN(_ context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *ExactSlidingLogLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *ExactSlidingLogLimiter) WaitN(ctx context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	return Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(n)
+	})
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过窗口请求上限
+func (l *ExactSlidingLogLimiter) Allow(_ context.Context, _ string, n int) (*Result, error) {
+	if err := checkPermits(n, l.limit); err != nil {
+		return nil, err
+	}
+	result := l.acquire(n)
+	return &result, nil
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，返回实际获取的许可数量，没有剩余许可时返回0
+func (l *ExactSlidingLogLimiter) TryAcquireUpTo(_ context.Context, _ string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now().UnixNano()
+	l.expire(now)
+	// 获取剩余的许可，但不超过n
+	granted := minInt(n, l.limit-l.size)
+	l.push(granted, now)
+	return granted, nil
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *ExactSlidingLogLimiter) tryAcquire(n int) (time.Duration, error) {
+	if result := l.acquire(n); !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *ExactSlidingLogLimiter) acquire(n int) Result {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now().UnixNano()
+	l.expire(now)
+	result := Result{Limit: l.limit}
+	// 若超过窗口请求上限，请求失败，需要等待到最早的size+n-limit个许可过期
+	if l.size+n > l.limit {
+		result.RetryAfter = time.Duration(l.at(l.size+n-l.limit-1) + l.window - now)
+	} else {
+		// 若没超过窗口请求上限，记录n个许可的获取时间，请求成功
+		l.push(n, now)
+		result.Allowed = true
+	}
+	result.Remaining = l.limit - l.size
+	// 最新的许可过期之后恢复到窗口请求上限
+	if l.size > 0 {
+		result.ResetAfter = time.Duration(l.at(l.size-1) + l.window - now)
+	}
+	return result
+}
+
+// 删除已经过期的获取时间，获取时间+窗口时间大小不晚于当前时间时过期
+func (l *ExactSlidingLogLimiter) expire(now int64) {
+	for l.size > 0 && l.logs[l.head]+l.window <= now {
+		l.head = (l.head + 1) % len(l.logs)
+		l.size--
+	}
+}
+
+// 记录n个许可的获取时间，调用方保证不超过窗口请求上限
+func (l *ExactSlidingLogLimiter) push(n int, now int64) {
+	for i := 0; i < n; i++ {
+		l.logs[(l.head+l.size)%len(l.logs)] = now
+		l.size++
+	}
+}
+
+// 从旧到新第i个许可的获取时间
+func (l *ExactSlidingLogLimiter) at(i int) int64 {
+	return l.logs[(l.head+i)%len(l.logs)]
+}
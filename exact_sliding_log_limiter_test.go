@@ -0,0 +1,49 @@
+package limiter
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestExactSlidingLogLimiter(t *testing.T) {
+	const (
+		limit  = 10
+		window = time.Second
+	)
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewExactSlidingLogLimiter(limit, window, WithClock(clock))
+	// 逐个遍历所有许可的获取时间，用于验证环形数组的实现
+	var logs []time.Time
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 10000; i++ {
+		clock.Advance(time.Duration(r.Intn(int(window / 5))))
+		now := clock.Now()
+		n := r.Intn(limit) + 1
+		var active []time.Time
+		for _, log := range logs {
+			if now.Sub(log) < window {
+				active = append(active, log)
+			}
+		}
+		want := Result{Limit: limit}
+		if len(active)+n > limit {
+			want.RetryAfter = active[len(active)+n-limit-1].Add(window).Sub(now)
+		} else {
+			for j := 0; j < n; j++ {
+				logs = append(logs, now)
+				active = append(active, now)
+			}
+			want.Allowed = true
+		}
+		want.Remaining = limit - len(active)
+		if len(active) > 0 {
+			want.ResetAfter = active[len(active)-1].Add(window).Sub(now)
+		}
+		got, _ := l.Allow(context.Background(), "test", n)
+		if *got != want {
+			t.Fatalf("%d Allow(%d) = %+v, want %+v", i, n, *got, want)
+		}
+	}
+}
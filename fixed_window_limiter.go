@@ -1,6 +1,7 @@
 package limiter
 
 import (
+	"context"
 	"sync"
 	"time"
 )
@@ -11,32 +12,110 @@ type FixedWindowLimiter struct {
 	window   time.Duration // 窗口时间大小
 	counter  int           // 计数器
 	lastTime time.Time     // 上一次请求的时间
+	clock    Clock         // 时钟
 	mutex    sync.Mutex    // 避免并发问题
 }
 
-func NewFixedWindowLimiter(limit int, window time.Duration) *FixedWindowLimiter {
+func NewFixedWindowLimiter(limit int, window time.Duration, opts ...Option) *FixedWindowLimiter {
+	o := newOptions(opts)
 	return &FixedWindowLimiter{
 		limit:    limit,
 		window:   window,
-		lastTime: time.Now(),
+		lastTime: o.clock.Now(),
+		clock:    o.clock,
 	}
 }
 
-func (l *FixedWindowLimiter) TryAcquire() bool {
+// TryAcquire 尝试获取许可，内存限流器只保护单个资源，因此忽略resource
+func (l *FixedWindowLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过窗口请求上限
+func (l *FixedWindowLimiter) TryAcquireN(_ context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *FixedWindowLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *FixedWindowLimiter) WaitN(ctx context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	return Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(n)
+	})
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过窗口请求上限
+func (l *FixedWindowLimiter) Allow(_ context.Context, _ string, n int) (*Result, error) {
+	if err := checkPermits(n, l.limit); err != nil {
+		return nil, err
+	}
+	result := l.acquire(n)
+	return &result, nil
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *FixedWindowLimiter) tryAcquire(n int) (time.Duration, error) {
+	if result := l.acquire(n); !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *FixedWindowLimiter) acquire(n int) Result {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 	// 获取当前时间
-	now := time.Now()
-	// 如果当前窗口失效，计数器清0，开启新的窗口
+	now := l.clock.Now()
+	l.refresh(now)
+	// 当前窗口失效的时间
+	resetAfter := l.window - now.Sub(l.lastTime) + time.Nanosecond
+	result := Result{Limit: l.limit}
+	// 若超过窗口请求上限，请求失败，需要等待到当前窗口失效
+	if l.counter+n > l.limit {
+		result.RetryAfter = resetAfter
+	} else {
+		// 若没超过窗口请求上限，计数器+n，请求成功
+		l.counter += n
+		result.Allowed = true
+	}
+	result.Remaining = l.limit - l.counter
+	if l.counter > 0 {
+		result.ResetAfter = resetAfter
+	}
+	return result
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，返回实际获取的许可数量，没有剩余许可时返回0
+func (l *FixedWindowLimiter) TryAcquireUpTo(_ context.Context, _ string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.refresh(l.clock.Now())
+	// 获取剩余的许可，但不超过n
+	granted := maxInt(0, minInt(n, l.limit-l.counter))
+	l.counter += granted
+	return granted, nil
+}
+
+// 如果当前窗口失效，计数器清0，开启新的窗口
+func (l *FixedWindowLimiter) refresh(now time.Time) {
 	if now.Sub(l.lastTime) > l.window {
 		l.counter = 0
 		l.lastTime = now
 	}
-	// 若到达窗口请求上限，请求失败
-	if l.counter >= l.limit {
-		return false
-	}
-	// 若没到窗口请求上限，计数器+1，请求成功
-	l.counter++
-	return true
 }
@@ -1,6 +1,7 @@
 package limiter
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -29,7 +30,7 @@ func TestNewFixedWindowLimiter(t *testing.T) {
 			l := NewFixedWindowLimiter(tt.args.limit, tt.args.window)
 			successCount := 0
 			for i := 0; i < tt.args.limit*2; i++ {
-				if l.TryAcquire() {
+				if l.TryAcquire(context.Background(), "test") == nil {
 					successCount++
 				}
 			}
@@ -39,7 +40,7 @@ func TestNewFixedWindowLimiter(t *testing.T) {
 			time.Sleep(time.Second)
 			successCount = 0
 			for i := 0; i < tt.args.limit*2; i++ {
-				if l.TryAcquire() {
+				if l.TryAcquire(context.Background(), "test") == nil {
 					successCount++
 				}
 			}
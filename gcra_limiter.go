@@ -0,0 +1,142 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// GCRALimiter 通用信元速率算法（GCRA）限流器，语义同令牌桶，但只需要保存一个理论到达时间
+// 每个令牌的发放间隔是1/速率，理论到达时间是令牌发放满的时间，获取n个许可时理论到达时间往后移动n个发放间隔，
+// 若移动之后的理论到达时间超过当前时间+容量个发放间隔，说明令牌不足，请求失败
+// 和TokenBucketLimiter不同，初始时令牌是满的
+type GCRALimiter struct {
+	capacity  int           // 容量
+	interval  time.Duration // 发放一个令牌的时间
+	tolerance time.Duration // 发放容量个令牌的时间，即允许的突发
+	tat       time.Time     // 理论到达时间，早于当前时间时说明令牌是满的
+	clock     Clock         // 时钟
+	mutex     sync.Mutex    // 避免并发问题
+}
+
+func NewGCRALimiter(capacity int, rate float64, opts ...Option) *GCRALimiter {
+	o := newOptions(opts)
+	l := &GCRALimiter{
+		capacity: capacity,
+		interval: secondsToDuration(1 / rate),
+		clock:    o.clock,
+	}
+	l.tolerance = l.emission(capacity)
+	return l
+}
+
+// TryAcquire 尝试获取许可，内存限流器只保护单个资源，因此忽略resource
+func (l *GCRALimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过容量
+func (l *GCRALimiter) TryAcquireN(_ context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *GCRALimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *GCRALimiter) WaitN(ctx context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return err
+	}
+	return Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(n)
+	})
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过容量
+func (l *GCRALimiter) Allow(_ context.Context, _ string, n int) (*Result, error) {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return nil, err
+	}
+	result := l.acquire(n)
+	return &result, nil
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，返回实际获取的许可数量，没有令牌时返回0
+func (l *GCRALimiter) TryAcquireUpTo(_ context.Context, _ string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now()
+	tat := l.refill(now)
+	// 获取剩余的令牌，但不超过n
+	granted := minInt(n, l.remaining(tat, now))
+	if granted > 0 {
+		l.tat = tat.Add(l.emission(granted))
+	}
+	return granted, nil
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *GCRALimiter) tryAcquire(n int) (time.Duration, error) {
+	if result := l.acquire(n); !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *GCRALimiter) acquire(n int) Result {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now()
+	tat := l.refill(now)
+	result := Result{Limit: l.capacity}
+	// 获取之后的理论到达时间，往前推容量个发放间隔就是允许获取的时间
+	newTat := tat.Add(l.emission(n))
+	if allowAt := newTat.Add(-l.tolerance); now.Before(allowAt) {
+		// 如果还没到允许获取的时间，说明令牌不足，请求失败，需要等待到允许获取的时间
+		result.RetryAfter = allowAt.Sub(now)
+	} else {
+		// 令牌足够，更新理论到达时间，请求成功
+		l.tat = newTat
+		tat = newTat
+		result.Allowed = true
+	}
+	result.Remaining = l.remaining(tat, now)
+	result.ResetAfter = tat.Sub(now)
+	return result
+}
+
+// 理论到达时间早于当前时间时令牌是满的，从当前时间开始计算
+func (l *GCRALimiter) refill(now time.Time) time.Time {
+	if l.tat.Before(now) {
+		return now
+	}
+	return l.tat
+}
+
+// 剩余的令牌数量，即当前时间+容量个发放间隔之前还能容纳的发放间隔数量
+func (l *GCRALimiter) remaining(tat, now time.Time) int {
+	return maxInt(0, int((l.tolerance-tat.Sub(now))/l.interval))
+}
+
+// 发放n个令牌需要的时间，速率为0时返回最大时间
+func (l *GCRALimiter) emission(n int) time.Duration {
+	d := float64(n) * float64(l.interval)
+	if d >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,35 @@
+package limiter
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestGCRALimiter(t *testing.T) {
+	// 和令牌满了之后的令牌桶执行相同的随机操作，结果应该完全一致
+	// 速率为4时发放间隔是250毫秒，令牌数量都是0.5的倍数，浮点数没有误差
+	clock := NewManualClock(time.Unix(0, 0))
+	want := NewTokenBucketLimiter(10, 4, WithClock(clock))
+	got := NewGCRALimiter(10, 4, WithClock(clock))
+	clock.Advance(time.Second * 10)
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 10000; i++ {
+		clock.Advance(time.Duration(r.Intn(9)) * time.Second / 8)
+		n := r.Intn(10) + 1
+		if r.Intn(4) == 0 {
+			wantN, _ := want.TryAcquireUpTo(context.Background(), "test", n)
+			gotN, _ := got.TryAcquireUpTo(context.Background(), "test", n)
+			if gotN != wantN {
+				t.Fatalf("%d TryAcquireUpTo(%d) = %v, want %v", i, n, gotN, wantN)
+			}
+			continue
+		}
+		wantResult, _ := want.Allow(context.Background(), "test", n)
+		gotResult, _ := got.Allow(context.Background(), "test", n)
+		if *gotResult != *wantResult {
+			t.Fatalf("%d Allow(%d) = %+v, want %+v", i, n, *gotResult, *wantResult)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package httplimiter
+
+import (
+	"context"
+	"github.com/jiaxwu/limiter"
+	"net"
+	"sort"
+	"strings"
+)
+
+var _ limiter.Limiter = (*CIDRLimiter)(nil)
+
+// CIDRLimiter 按网络选择限流器，用于给办公网络、合作方出口等网络设置不同的限制或者不限流
+// 资源需要是IP或者SubnetKeyFunc返回的网络，优先匹配前缀最长的网络，都不匹配或者资源不是IP时使用默认限流器
+type CIDRLimiter struct {
+	rules    []*cidrRule     // 网络规则，按前缀长度从长到短排序
+	fallback limiter.Limiter // 默认限流器
+}
+
+// 网络规则
+type cidrRule struct {
+	network *net.IPNet      // 网络
+	limiter limiter.Limiter // 限流器，为nil时不限流
+}
+
+// NewCIDRLimiter overrides的键是CIDR或者IP，值是该网络使用的限流器，值为nil时该网络不限流
+func NewCIDRLimiter(fallback limiter.Limiter, overrides map[string]limiter.Limiter) (*CIDRLimiter, error) {
+	rules := make([]*cidrRule, 0, len(overrides))
+	for cidr, l := range overrides {
+		network, err := parseNetwork(cidr)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, &cidrRule{network: network, limiter: l})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		a, _ := rules[i].network.Mask.Size()
+		b, _ := rules[j].network.Mask.Size()
+		return a > b
+	})
+	return &CIDRLimiter{
+		rules:    rules,
+		fallback: fallback,
+	}, nil
+}
+
+// TryAcquire 尝试获取许可，不限流的网络总是成功
+func (l *CIDRLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，不限流的网络总是成功
+func (l *CIDRLimiter) TryAcquireN(ctx context.Context, resource string, n int) error {
+	if ll := l.limiter(resource); ll != nil {
+		return ll.TryAcquireN(ctx, resource, n)
+	}
+	return nil
+}
+
+// Wait 阻塞直到获取许可，不限流的网络直接返回
+func (l *CIDRLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，不限流的网络直接返回
+func (l *CIDRLimiter) WaitN(ctx context.Context, resource string, n int) error {
+	if ll := l.limiter(resource); ll != nil {
+		return ll.WaitN(ctx, resource, n)
+	}
+	return nil
+}
+
+// Allow 尝试获取n个许可，返回获取结果，不限流的网络总是成功，并且Result.Limit为0
+func (l *CIDRLimiter) Allow(ctx context.Context, resource string, n int) (*limiter.Result, error) {
+	if ll := l.limiter(resource); ll != nil {
+		return ll.Allow(ctx, resource, n)
+	}
+	return &limiter.Result{Allowed: true}, nil
+}
+
+// 获取资源对应的限流器，不限流时返回nil
+func (l *CIDRLimiter) limiter(resource string) limiter.Limiter {
+	// 资源可能是IP或者网络
+	ip := net.ParseIP(resource)
+	if ip == nil {
+		if network, err := parseNetwork(resource); err == nil {
+			ip = network.IP
+		}
+	}
+	if ip != nil {
+		for _, rule := range l.rules {
+			if rule.network.Contains(ip) {
+				return rule.limiter
+			}
+		}
+	}
+	return l.fallback
+}
+
+// 解析CIDR或者IP，IP当成只有一个地址的网络
+func parseNetwork(cidr string) (*net.IPNet, error) {
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "IP address", Text: cidr}
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	return network, err
+}
@@ -0,0 +1,48 @@
+package httplimiter
+
+import (
+	"context"
+	"github.com/jiaxwu/limiter"
+	"testing"
+	"time"
+)
+
+func TestCIDRLimiter(t *testing.T) {
+	l, err := NewCIDRLimiter(limiter.NewFixedWindowLimiter(1, time.Second), map[string]limiter.Limiter{
+		// 合作方网络有更高的限制
+		"198.51.100.0/24": limiter.NewFixedWindowLimiter(3, time.Second),
+		// 办公网络不限流，但是其中的一台机器正常限流
+		"203.0.113.0/24": nil,
+		"203.0.113.1":    limiter.NewFixedWindowLimiter(2, time.Second),
+		"2001:db8::/32":  nil,
+	})
+	if err != nil {
+		t.Fatalf("NewCIDRLimiter() error = %v", err)
+	}
+	tests := []struct {
+		name     string
+		resource string
+		want     []bool // 每次请求是否成功
+	}{
+		{name: "fallback", resource: "192.0.2.1", want: []bool{true, false}},
+		// 不是IP时使用默认限流器，内存限流器忽略资源，因此和上一个用例共用许可
+		{name: "not_ip", resource: "user", want: []bool{false}},
+		{name: "override", resource: "198.51.100.7", want: []bool{true, true, true, false}},
+		{name: "exempt", resource: "203.0.113.9", want: []bool{true, true, true, true}},
+		{name: "exempt_subnet", resource: "2001:db8:1:2::/64", want: []bool{true, true, true, true}},
+		{name: "most_specific", resource: "203.0.113.1", want: []bool{true, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, want := range tt.want {
+				result, err := l.Allow(context.Background(), tt.resource, 1)
+				if err != nil || result.Allowed != want {
+					t.Errorf("Allow() %d = %+v, %v, want %v", i, result, err, want)
+				}
+			}
+		})
+	}
+	if _, err := NewCIDRLimiter(nil, map[string]limiter.Limiter{"invalid": nil}); err == nil {
+		t.Errorf("NewCIDRLimiter() error = nil, want error")
+	}
+}
@@ -0,0 +1,192 @@
+package httplimiter
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrKeyNotFound 请求中没有对应的资源
+var ErrKeyNotFound = errors.New("key not found")
+
+// IPKeyFunc 使用客户端IP作为资源
+// 只有直接连接的地址属于可信代理时，才会从右往左解析Forwarded或者X-Forwarded-For（优先使用Forwarded），
+// 跳过可信代理的地址，第一个不属于可信代理的地址就是客户端IP，避免客户端伪造这两个头
+// trustedProxies是可信代理的CIDR或者IP，为空时总是使用直接连接的地址
+func IPKeyFunc(trustedProxies ...string) (KeyFunc, error) {
+	proxies := make([]*net.IPNet, 0, len(trustedProxies))
+	for _, proxy := range trustedProxies {
+		network, err := parseNetwork(proxy)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, network)
+	}
+	trusted := func(ip net.IP) bool {
+		for _, proxy := range proxies {
+			if proxy.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(r *http.Request) (string, error) {
+		ip := parseIP(r.RemoteAddr)
+		if ip == nil {
+			return "", ErrKeyNotFound
+		}
+		if !trusted(ip) {
+			return ip.String(), nil
+		}
+		// 从右往左跳过可信代理，遇到无法解析的地址时使用上一个可信代理的地址
+		hops := forwardedFor(r)
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := parseIP(hops[i])
+			if hop == nil {
+				break
+			}
+			ip = hop
+			if !trusted(ip) {
+				break
+			}
+		}
+		return ip.String(), nil
+	}, nil
+}
+
+// SubnetKeyFunc 把keyFunc返回的IP归一化为所在的网络，例如203.0.113.0/24，避免攻击者在同一个网络内轮换IP
+// ipv4Bits和ipv6Bits是网络前缀长度，例如IPv4使用24，IPv6使用64或者48
+func SubnetKeyFunc(keyFunc KeyFunc, ipv4Bits, ipv6Bits int) (KeyFunc, error) {
+	if ipv4Bits < 0 || ipv4Bits > 8*net.IPv4len || ipv6Bits < 0 || ipv6Bits > 8*net.IPv6len {
+		return nil, errors.New("invalid prefix bits")
+	}
+	return func(r *http.Request) (string, error) {
+		key, err := keyFunc(r)
+		if err != nil {
+			return "", err
+		}
+		ip := net.ParseIP(key)
+		if ip == nil {
+			return "", errors.New("invalid ip " + key)
+		}
+		network := &net.IPNet{IP: ip.To16(), Mask: net.CIDRMask(ipv6Bits, 8*net.IPv6len)}
+		if ip4 := ip.To4(); ip4 != nil {
+			network = &net.IPNet{IP: ip4, Mask: net.CIDRMask(ipv4Bits, 8*net.IPv4len)}
+		}
+		network.IP = network.IP.Mask(network.Mask)
+		return network.String(), nil
+	}, nil
+}
+
+// HeaderKeyFunc 使用请求头的值作为资源，请求头不存在时返回ErrKeyNotFound
+func HeaderKeyFunc(name string) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		value := r.Header.Get(name)
+		if value == "" {
+			return "", ErrKeyNotFound
+		}
+		return value, nil
+	}
+}
+
+// QueryKeyFunc 使用查询参数的值作为资源，查询参数不存在时返回ErrKeyNotFound
+func QueryKeyFunc(name string) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			return "", ErrKeyNotFound
+		}
+		return value, nil
+	}
+}
+
+// BasicAuthUserKeyFunc 使用Basic认证的用户名作为资源，没有Basic认证时返回ErrKeyNotFound
+// 只获取用户名，不校验密码，需要在认证之后再限流或者和其他资源组合使用
+func BasicAuthUserKeyFunc() KeyFunc {
+	return func(r *http.Request) (string, error) {
+		user, _, ok := r.BasicAuth()
+		if !ok || user == "" {
+			return "", ErrKeyNotFound
+		}
+		return user, nil
+	}
+}
+
+// PathKeyFunc 使用请求路径作为资源
+func PathKeyFunc() KeyFunc {
+	return func(r *http.Request) (string, error) {
+		return r.URL.Path, nil
+	}
+}
+
+// MethodKeyFunc 使用请求方法作为资源
+func MethodKeyFunc() KeyFunc {
+	return func(r *http.Request) (string, error) {
+		return r.Method, nil
+	}
+}
+
+// JoinKeyFuncs 用冒号连接多个资源，例如按IP+路径限流，任意一个出错时返回错误
+func JoinKeyFuncs(keyFuncs ...KeyFunc) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		keys := make([]string, len(keyFuncs))
+		for i, keyFunc := range keyFuncs {
+			key, err := keyFunc(r)
+			if err != nil {
+				return "", err
+			}
+			keys[i] = key
+		}
+		return strings.Join(keys, ":"), nil
+	}
+}
+
+// FirstKeyFunc 使用第一个获取成功的资源，例如有API Key时按API Key限流，否则按IP限流，全部失败时返回最后一个错误
+func FirstKeyFunc(keyFuncs ...KeyFunc) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		err := ErrKeyNotFound
+		for _, keyFunc := range keyFuncs {
+			var key string
+			if key, err = keyFunc(r); err == nil {
+				return key, nil
+			}
+		}
+		return "", err
+	}
+}
+
+// 获取转发链路上的地址，从客户端到最后一个代理
+func forwardedFor(r *http.Request) []string {
+	var hops []string
+	// 优先使用标准的Forwarded头，例如：Forwarded: for=192.0.2.60;proto=http, for="[2001:db8::17]:4711"
+	if values := r.Header.Values("Forwarded"); len(values) > 0 {
+		for _, value := range values {
+			for _, element := range strings.Split(value, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if ok && strings.EqualFold(key, "for") {
+						hops = append(hops, strings.Trim(value, `"`))
+					}
+				}
+			}
+		}
+		return hops
+	}
+	// X-Forwarded-For: client, proxy1, proxy2
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// 解析可能带端口的地址，例如：192.0.2.60、192.0.2.60:80、[2001:db8::17]:4711、2001:db8::17
+func parseIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(strings.Trim(addr, "[]"))
+}
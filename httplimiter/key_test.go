@@ -0,0 +1,165 @@
+package httplimiter
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPKeyFunc(t *testing.T) {
+	keyFunc, err := IPKeyFunc("10.0.0.0/8", "192.168.1.1", "2001:db8::/32")
+	if err != nil {
+		t.Fatalf("IPKeyFunc() error = %v", err)
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{
+			name:       "direct",
+			remoteAddr: "203.0.113.1:1234",
+			want:       "203.0.113.1",
+		},
+		{
+			name:       "untrusted_remote_ignore_headers",
+			remoteAddr: "203.0.113.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "203.0.113.1",
+		},
+		{
+			name:       "x_forwarded_for",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1, 192.168.1.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "spoofed_x_forwarded_for",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.1.1.1, 198.51.100.1"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "multiple_x_forwarded_for",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1", "10.0.0.2"}},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "all_trusted",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "invalid_hop",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string][]string{"X-Forwarded-For": {"unknown, 10.0.0.2"}},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "forwarded",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string][]string{
+				"Forwarded":       {`for=198.51.100.1;proto=http, For="[2001:db8::17]:4711"`},
+				"X-Forwarded-For": {"1.1.1.1"},
+			},
+			want: "198.51.100.1",
+		},
+		{
+			name:       "forwarded_ipv6",
+			remoteAddr: "[2001:db8::1]:1234",
+			headers:    map[string][]string{"Forwarded": {`for="[2001:db9::17]:4711"`}},
+			want:       "2001:db9::17",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for key, values := range tt.headers {
+				for _, value := range values {
+					r.Header.Add(key, value)
+				}
+			}
+			if got, err := keyFunc(r); err != nil || got != tt.want {
+				t.Errorf("keyFunc() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+	if _, err := IPKeyFunc("10.0.0.0/33"); err == nil {
+		t.Errorf("IPKeyFunc() error = nil, want error")
+	}
+}
+
+func TestKeyFuncs(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/users?tenant=a", nil)
+	r.RemoteAddr = "203.0.113.1:1234"
+	r.Header.Set("X-Api-Key", "key")
+	r.SetBasicAuth("user", "password")
+	ipKeyFunc, _ := IPKeyFunc()
+	tests := []struct {
+		name    string
+		keyFunc KeyFunc
+		want    string
+		wantErr error
+	}{
+		{name: "header", keyFunc: HeaderKeyFunc("X-Api-Key"), want: "key"},
+		{name: "missing_header", keyFunc: HeaderKeyFunc("X-Missing"), wantErr: ErrKeyNotFound},
+		{name: "query", keyFunc: QueryKeyFunc("tenant"), want: "a"},
+		{name: "missing_query", keyFunc: QueryKeyFunc("missing"), wantErr: ErrKeyNotFound},
+		{name: "basic_auth_user", keyFunc: BasicAuthUserKeyFunc(), want: "user"},
+		{name: "path", keyFunc: PathKeyFunc(), want: "/users"},
+		{name: "method", keyFunc: MethodKeyFunc(), want: http.MethodPost},
+		{name: "join", keyFunc: JoinKeyFuncs(ipKeyFunc, MethodKeyFunc(), PathKeyFunc()), want: "203.0.113.1:POST:/users"},
+		{
+			name:    "join_error",
+			keyFunc: JoinKeyFuncs(ipKeyFunc, HeaderKeyFunc("X-Missing")),
+			wantErr: ErrKeyNotFound,
+		},
+		{name: "first", keyFunc: FirstKeyFunc(HeaderKeyFunc("X-Missing"), ipKeyFunc), want: "203.0.113.1"},
+		{name: "first_error", keyFunc: FirstKeyFunc(HeaderKeyFunc("X-Missing")), wantErr: ErrKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.keyFunc(r)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("keyFunc() = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubnetKeyFunc(t *testing.T) {
+	ipKeyFunc, _ := IPKeyFunc()
+	keyFunc, err := SubnetKeyFunc(ipKeyFunc, 24, 64)
+	if err != nil {
+		t.Fatalf("SubnetKeyFunc() error = %v", err)
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{
+		{name: "ipv4", remoteAddr: "203.0.113.77:1234", want: "203.0.113.0/24"},
+		{name: "ipv4_mapped", remoteAddr: "[::ffff:203.0.113.77]:1234", want: "203.0.113.0/24"},
+		{name: "ipv6", remoteAddr: "[2001:db8:1:2:3:4:5:6]:1234", want: "2001:db8:1:2::/64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if got, err := keyFunc(r); err != nil || got != tt.want {
+				t.Errorf("keyFunc() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+	if _, err := SubnetKeyFunc(ipKeyFunc, 33, 64); err == nil {
+		t.Errorf("SubnetKeyFunc() error = nil, want error")
+	}
+	if _, err := SubnetKeyFunc(HeaderKeyFunc("X-Missing"), 24, 64); err != nil {
+		t.Errorf("SubnetKeyFunc() error = %v", err)
+	}
+}
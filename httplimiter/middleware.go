@@ -0,0 +1,98 @@
+package httplimiter
+
+import (
+	"errors"
+	"github.com/jiaxwu/limiter"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// KeyFunc 从请求中获取限流的资源
+type KeyFunc func(r *http.Request) (string, error)
+
+// DenyHandler 请求被限流时的处理器
+type DenyHandler func(w http.ResponseWriter, r *http.Request, result *limiter.Result)
+
+// ErrorHandler 获取资源或者获取许可失败时的处理器
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// Middleware 限流中间件，请求被限流时返回429，并设置Retry-After和RateLimit头
+type Middleware struct {
+	limiter      limiter.Limiter // 限流器
+	keyFunc      KeyFunc         // 获取资源
+	permits      int             // 每个请求需要的许可数量
+	window       time.Duration   // 限流窗口时间大小，用于RateLimit-Policy头
+	denyHandler  DenyHandler     // 请求被限流时的处理器
+	errorHandler ErrorHandler    // 出错时的处理器
+}
+
+func NewMiddleware(l limiter.Limiter, keyFunc KeyFunc, opts ...Option) *Middleware {
+	o := newOptions(opts)
+	return &Middleware{
+		limiter:      l,
+		keyFunc:      keyFunc,
+		permits:      o.permits,
+		window:       o.window,
+		denyHandler:  o.denyHandler,
+		errorHandler: o.errorHandler,
+	}
+}
+
+// Handler 包装next，只有获取许可成功的请求才会交给next处理
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, err := m.keyFunc(r)
+		if err != nil {
+			m.errorHandler(w, r, err)
+			return
+		}
+		result, err := m.limiter.Allow(r.Context(), key, m.permits)
+		if err != nil {
+			m.errorHandler(w, r, err)
+			return
+		}
+		// 许可上限为0表示不限流，例如CIDRLimiter中不限流的网络，不需要设置RateLimit头
+		if result.Limit > 0 {
+			m.setHeaders(w.Header(), result)
+		}
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.FormatInt(seconds(result.RetryAfter), 10))
+			m.denyHandler(w, r, result)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// 设置RateLimit头
+func (m *Middleware) setHeaders(header http.Header, result *limiter.Result) {
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.FormatInt(seconds(result.ResetAfter), 10))
+	policy := strconv.Itoa(result.Limit)
+	if m.window > 0 {
+		policy += ";w=" + strconv.FormatInt(seconds(m.window), 10)
+	}
+	header.Set("RateLimit-Policy", policy)
+}
+
+// DefaultDenyHandler 默认的限流处理器，返回429
+func DefaultDenyHandler(w http.ResponseWriter, _ *http.Request, _ *limiter.Result) {
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
+
+// DefaultErrorHandler 默认的出错处理器，请求中没有对应的资源时返回400，否则返回500
+func DefaultErrorHandler(w http.ResponseWriter, _ *http.Request, err error) {
+	if errors.Is(err, ErrKeyNotFound) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// 把时间向上取整为秒，头里的时间单位都是秒
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
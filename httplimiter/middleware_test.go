@@ -0,0 +1,92 @@
+package httplimiter
+
+import (
+	"errors"
+	"github.com/jiaxwu/limiter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	keyFunc := func(r *http.Request) (string, error) {
+		return "test", nil
+	}
+	tests := []struct {
+		name        string
+		opts        []Option
+		keyFunc     KeyFunc
+		wantCodes   []int               // 每次请求的状态码
+		wantHeaders []map[string]string // 每次请求的头
+	}{
+		{
+			name:      "default",
+			keyFunc:   keyFunc,
+			wantCodes: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+			wantHeaders: []map[string]string{
+				{"RateLimit-Limit": "2", "RateLimit-Remaining": "1", "RateLimit-Reset": "1", "RateLimit-Policy": "2"},
+				{"RateLimit-Limit": "2", "RateLimit-Remaining": "0", "RateLimit-Reset": "1", "Retry-After": ""},
+				{"RateLimit-Limit": "2", "RateLimit-Remaining": "0", "RateLimit-Reset": "1", "Retry-After": "1"},
+			},
+		},
+		{
+			name:      "window_and_permits",
+			opts:      []Option{WithWindow(time.Second), WithPermits(2)},
+			keyFunc:   keyFunc,
+			wantCodes: []int{http.StatusOK, http.StatusTooManyRequests},
+			wantHeaders: []map[string]string{
+				{"RateLimit-Remaining": "0", "RateLimit-Policy": "2;w=1"},
+				{"RateLimit-Remaining": "0", "RateLimit-Policy": "2;w=1", "Retry-After": "1"},
+			},
+		},
+		{
+			name: "deny_handler",
+			opts: []Option{WithDenyHandler(func(w http.ResponseWriter, r *http.Request, result *limiter.Result) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			})},
+			keyFunc:   keyFunc,
+			wantCodes: []int{http.StatusOK, http.StatusOK, http.StatusServiceUnavailable},
+		},
+		{
+			name:      "key_not_found",
+			keyFunc:   HeaderKeyFunc("X-Missing"),
+			wantCodes: []int{http.StatusBadRequest},
+		},
+		{
+			name: "error_handler",
+			opts: []Option{WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+				w.WriteHeader(http.StatusBadRequest)
+			})},
+			keyFunc: func(r *http.Request) (string, error) {
+				return "", errors.New("no key")
+			},
+			wantCodes: []int{http.StatusBadRequest},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := limiter.NewSlidingWindowLimiter(
+				2, time.Second, time.Second/10, limiter.WithClock(limiter.NewManualClock(time.Unix(0, 0))))
+			handler := NewMiddleware(l, tt.keyFunc, tt.opts...).Handler(okHandler)
+			for i, wantCode := range tt.wantCodes {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+				if w.Code != wantCode {
+					t.Errorf("ServeHTTP() %d code = %v, want %v", i, w.Code, wantCode)
+				}
+				if i >= len(tt.wantHeaders) {
+					continue
+				}
+				for key, want := range tt.wantHeaders[i] {
+					if got := w.Header().Get(key); got != want {
+						t.Errorf("ServeHTTP() %d header %s = %v, want %v", i, key, got, want)
+					}
+				}
+			}
+		})
+	}
+}
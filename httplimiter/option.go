@@ -0,0 +1,53 @@
+package httplimiter
+
+import "time"
+
+// Option 中间件选项
+type Option func(*options)
+
+type options struct {
+	permits      int           // 每个请求需要的许可数量
+	window       time.Duration // 限流窗口时间大小
+	denyHandler  DenyHandler   // 请求被限流时的处理器
+	errorHandler ErrorHandler  // 出错时的处理器
+}
+
+// WithPermits 设置每个请求需要的许可数量，默认是1
+func WithPermits(permits int) Option {
+	return func(o *options) {
+		o.permits = permits
+	}
+}
+
+// WithWindow 设置限流窗口时间大小，用于RateLimit-Policy头的w参数，默认不设置w参数
+func WithWindow(window time.Duration) Option {
+	return func(o *options) {
+		o.window = window
+	}
+}
+
+// WithDenyHandler 设置请求被限流时的处理器，默认是DefaultDenyHandler
+func WithDenyHandler(handler DenyHandler) Option {
+	return func(o *options) {
+		o.denyHandler = handler
+	}
+}
+
+// WithErrorHandler 设置获取资源或者获取许可失败时的处理器，默认是DefaultErrorHandler
+func WithErrorHandler(handler ErrorHandler) Option {
+	return func(o *options) {
+		o.errorHandler = handler
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		permits:      1,
+		denyHandler:  DefaultDenyHandler,
+		errorHandler: DefaultErrorHandler,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,166 @@
+package limiter
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// KeyedLimiter 按资源限流的内存限流器，资源是字符串
+type KeyedLimiter = GenericKeyedLimiter[string]
+
+func NewKeyedLimiter(newLimiter func() Limiter, maxKeys int, idleTimeout time.Duration, opts ...Option) *KeyedLimiter {
+	l := NewGenericKeyedLimiter[string](newLimiter, maxKeys, idleTimeout, opts...)
+	// 把资源传给限流器，方便组合其他按资源限流的限流器
+	l.resource = func(key string) string {
+		return key
+	}
+	return l
+}
+
+// GenericKeyedLimiter 按资源限流的内存限流器，每个资源使用一个独立的限流器，资源可以是任意可比较的类型，例如结构体
+// 资源数量超过上限时淘汰最久没有使用的资源，空闲超过一定时间的资源由后台协程清理，不再使用时需要调用Stop
+// 资源被淘汰或者清理之后，再次使用时会创建新的限流器，因此空闲时间应该不小于限流器恢复到初始状态需要的时间
+type GenericKeyedLimiter[K comparable] struct {
+	newLimiter  func() Limiter      // 创建资源的限流器
+	resource    func(key K) string  // 传给限流器的资源，内存限流器忽略资源，因此默认是空字符串
+	maxKeys     int                 // 资源数量上限，小于等于0时不限制
+	idleTimeout time.Duration       // 资源空闲超过该时间之后被清理，小于等于0时不清理
+	entries     map[K]*list.Element // 资源对应的LRU链表元素
+	lru         *list.List          // LRU链表，最近使用的资源在前面
+	clock       Clock               // 时钟
+	mutex       sync.Mutex          // 避免并发问题
+	stop        chan struct{}       // 通知后台协程退出
+	done        chan struct{}       // 后台协程已经退出
+	stopOnce    sync.Once           // 避免重复Stop
+}
+
+// 资源的限流器
+type keyedEntry[K comparable] struct {
+	key      K         // 资源
+	limiter  Limiter   // 限流器
+	lastTime time.Time // 上次使用的时间
+}
+
+func NewGenericKeyedLimiter[K comparable](
+	newLimiter func() Limiter, maxKeys int, idleTimeout time.Duration, opts ...Option) *GenericKeyedLimiter[K] {
+	l := &GenericKeyedLimiter[K]{
+		newLimiter: newLimiter,
+		resource: func(K) string {
+			return ""
+		},
+		maxKeys:     maxKeys,
+		idleTimeout: idleTimeout,
+		entries:     make(map[K]*list.Element),
+		lru:         list.New(),
+		clock:       newOptions(opts).clock,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if idleTimeout > 0 {
+		go l.janitor()
+	} else {
+		close(l.done)
+	}
+	return l
+}
+
+// TryAcquire 尝试获取资源的许可
+func (l *GenericKeyedLimiter[K]) TryAcquire(ctx context.Context, key K) error {
+	return l.TryAcquireN(ctx, key, 1)
+}
+
+// TryAcquireN 尝试获取资源的n个许可，要么全部获取，要么都不获取
+func (l *GenericKeyedLimiter[K]) TryAcquireN(ctx context.Context, key K, n int) error {
+	return l.limiter(key).TryAcquireN(ctx, l.resource(key), n)
+}
+
+// Wait 阻塞直到获取资源的许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *GenericKeyedLimiter[K]) Wait(ctx context.Context, key K) error {
+	return l.WaitN(ctx, key, 1)
+}
+
+// WaitN 阻塞直到获取资源的n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *GenericKeyedLimiter[K]) WaitN(ctx context.Context, key K, n int) error {
+	return l.limiter(key).WaitN(ctx, l.resource(key), n)
+}
+
+// Allow 尝试获取资源的n个许可，返回获取结果
+func (l *GenericKeyedLimiter[K]) Allow(ctx context.Context, key K, n int) (*Result, error) {
+	return l.limiter(key).Allow(ctx, l.resource(key), n)
+}
+
+// Len 当前保存的资源数量
+func (l *GenericKeyedLimiter[K]) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.lru.Len()
+}
+
+// Stop 停止后台清理协程，可以重复调用
+func (l *GenericKeyedLimiter[K]) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+	<-l.done
+}
+
+// 获取资源的限流器，不存在时创建，超过资源数量上限时淘汰最久没有使用的资源
+func (l *GenericKeyedLimiter[K]) limiter(key K) Limiter {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now()
+	if element, ok := l.entries[key]; ok {
+		entry := element.Value.(*keyedEntry[K])
+		entry.lastTime = now
+		l.lru.MoveToFront(element)
+		return entry.limiter
+	}
+	if l.maxKeys > 0 && l.lru.Len() >= l.maxKeys {
+		l.remove(l.lru.Back())
+	}
+	entry := &keyedEntry[K]{
+		key:      key,
+		limiter:  l.newLimiter(),
+		lastTime: now,
+	}
+	l.entries[key] = l.lru.PushFront(entry)
+	return entry.limiter
+}
+
+// 后台清理空闲的资源
+func (l *GenericKeyedLimiter[K]) janitor() {
+	defer close(l.done)
+	for {
+		timer := l.clock.NewTimer(l.idleTimeout)
+		select {
+		case <-timer.C():
+			l.removeIdle()
+		case <-l.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// 从最久没有使用的资源开始，清理空闲超过idleTimeout的资源
+func (l *GenericKeyedLimiter[K]) removeIdle() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now()
+	for element := l.lru.Back(); element != nil; element = l.lru.Back() {
+		if now.Sub(element.Value.(*keyedEntry[K]).lastTime) < l.idleTimeout {
+			return
+		}
+		l.remove(element)
+	}
+}
+
+// 删除资源
+func (l *GenericKeyedLimiter[K]) remove(element *list.Element) {
+	l.lru.Remove(element)
+	delete(l.entries, element.Value.(*keyedEntry[K]).key)
+}
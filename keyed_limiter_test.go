@@ -0,0 +1,125 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestKeyedLimiter(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	newLimiter := func() Limiter {
+		return NewFixedWindowLimiter(1, time.Hour, WithClock(clock))
+	}
+	tests := []struct {
+		name      string
+		maxKeys   int
+		resources []string // 依次请求的资源
+		want      []bool   // 每次请求是否成功
+		wantLen   int      // 最后保存的资源数量
+	}{
+		{
+			name:      "per_key",
+			resources: []string{"a", "a", "b", "b"},
+			want:      []bool{true, false, true, false},
+			wantLen:   2,
+		},
+		{
+			name:    "lru_eviction",
+			maxKeys: 2,
+			// c淘汰最久没有使用的b，b重新创建限流器
+			resources: []string{"a", "b", "a", "c", "b", "a"},
+			want:      []bool{true, true, false, true, true, true},
+			wantLen:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewKeyedLimiter(newLimiter, tt.maxKeys, 0, WithClock(clock))
+			defer l.Stop()
+			for i, resource := range tt.resources {
+				if got := l.TryAcquire(context.Background(), resource) == nil; got != tt.want[i] {
+					t.Errorf("TryAcquire(%s) %d got = %v, want %v", resource, i, got, tt.want[i])
+				}
+			}
+			if got := l.Len(); got != tt.wantLen {
+				t.Errorf("Len() = %v, want %v", got, tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestKeyedLimiterIdleTimeout(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewKeyedLimiter(func() Limiter {
+		return NewFixedWindowLimiter(1, time.Hour, WithClock(clock))
+	}, 0, time.Minute, WithClock(clock))
+	defer l.Stop()
+
+	l.TryAcquire(context.Background(), "a")
+	// 等待后台协程开始等待
+	waitFor(t, func() bool { return clock.PendingTimers() == 1 })
+	clock.Advance(time.Second * 30)
+	l.TryAcquire(context.Background(), "b")
+	// 第一次清理时a空闲了1分钟，b只空闲了30秒
+	clock.Advance(time.Second * 30)
+	waitFor(t, func() bool { return l.Len() == 1 })
+	if err := l.TryAcquire(context.Background(), "b"); err == nil {
+		t.Errorf("TryAcquire(b) error = nil, want error")
+	}
+	// 第二次清理时b空闲了1分钟
+	waitFor(t, func() bool { return clock.PendingTimers() == 1 })
+	clock.Advance(time.Minute)
+	waitFor(t, func() bool { return l.Len() == 0 })
+	if err := l.TryAcquire(context.Background(), "a"); err != nil {
+		t.Errorf("TryAcquire(a) error = %v, want nil", err)
+	}
+
+	// Stop之后后台协程退出，可以重复调用
+	l.Stop()
+	l.Stop()
+	if got := clock.PendingTimers(); got != 0 {
+		t.Errorf("PendingTimers() = %v, want 0", got)
+	}
+}
+
+// 等待条件成立，超时则测试失败
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGenericKeyedLimiter(t *testing.T) {
+	type key struct {
+		tenantID int
+		route    string
+	}
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewGenericKeyedLimiter[key](func() Limiter {
+		return NewFixedWindowLimiter(1, time.Hour, WithClock(clock))
+	}, 2, 0, WithClock(clock))
+	defer l.Stop()
+	tests := []struct {
+		key  key
+		want bool
+	}{
+		{key: key{tenantID: 1, route: "/a"}, want: true},
+		{key: key{tenantID: 1, route: "/a"}, want: false},
+		{key: key{tenantID: 1, route: "/b"}, want: true},
+		{key: key{tenantID: 2, route: "/a"}, want: true},
+	}
+	for i, tt := range tests {
+		if got := l.TryAcquire(context.Background(), tt.key) == nil; got != tt.want {
+			t.Errorf("TryAcquire(%+v) %d got = %v, want %v", tt.key, i, got, tt.want)
+		}
+	}
+	if got := l.Len(); got != 2 {
+		t.Errorf("Len() = %v, want 2", got)
+	}
+}
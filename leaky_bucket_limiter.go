@@ -1,6 +1,8 @@
 package limiter
 
 import (
+	"context"
+	"math"
 	"sync"
 	"time"
 )
@@ -8,41 +10,142 @@ import (
 // LeakyBucketLimiter 漏桶限流器
 type LeakyBucketLimiter struct {
 	peakLevel       int        // 最高水位
-	currentLevel    int        // 当前水位
-	currentVelocity int        // 水流速度/秒
+	currentLevel    float64    // 当前水位，按时间连续放水，因此可能是小数，预留许可时可能超过最高水位
+	currentVelocity float64    // 水流速度/秒
 	lastTime        time.Time  // 上次放水时间
+	clock           Clock      // 时钟
 	mutex           sync.Mutex // 避免并发问题
 }
 
-func NewLeakyBucketLimiter(peakLevel, currentVelocity int) *LeakyBucketLimiter {
+func NewLeakyBucketLimiter(peakLevel int, currentVelocity float64, opts ...Option) *LeakyBucketLimiter {
+	o := newOptions(opts)
 	return &LeakyBucketLimiter{
 		peakLevel:       peakLevel,
 		currentVelocity: currentVelocity,
-		lastTime:        time.Now(),
+		lastTime:        o.clock.Now(),
+		clock:           o.clock,
 	}
 }
 
-func (l *LeakyBucketLimiter) TryAcquire() bool {
+// TryAcquire 尝试获取许可，内存限流器只保护单个资源，因此忽略resource
+func (l *LeakyBucketLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过最高水位
+func (l *LeakyBucketLimiter) TryAcquireN(_ context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.peakLevel); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *LeakyBucketLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *LeakyBucketLimiter) WaitN(ctx context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.peakLevel); err != nil {
+		return err
+	}
+	return Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(n)
+	})
+}
+
+// Reserve 预留一个许可，水位不足时预支未来的水位，调用方需要等待Reservation.Delay()之后再执行操作
+func (l *LeakyBucketLimiter) Reserve(ctx context.Context, resource string) *Reservation {
+	return l.ReserveN(ctx, resource, 1)
+}
+
+// ReserveN 预留n个许可，其他同Reserve
+func (l *LeakyBucketLimiter) ReserveN(_ context.Context, _ string, n int) *Reservation {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// 许可数量不合法或者超过最高水位，永远无法满足
+	if checkPermits(n, l.peakLevel) != nil {
+		return &Reservation{}
+	}
+	now := l.clock.Now()
+	l.leak(now)
+	// 预支n个水位
+	l.currentLevel += float64(n)
+	return &Reservation{
+		ok:        true,
+		timeToAct: now.Add(l.waitLevel(l.peakLevel)),
+		clock:     l.clock,
+		cancel: func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			// 归还水位，但不能低于0
+			l.leak(l.clock.Now())
+			l.currentLevel = math.Max(0, l.currentLevel-float64(n))
+		},
+	}
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过最高水位
+func (l *LeakyBucketLimiter) Allow(_ context.Context, _ string, n int) (*Result, error) {
+	if err := checkPermits(n, l.peakLevel); err != nil {
+		return nil, err
+	}
+	result := l.acquire(n)
+	return &result, nil
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *LeakyBucketLimiter) tryAcquire(n int) (time.Duration, error) {
+	if result := l.acquire(n); !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *LeakyBucketLimiter) acquire(n int) Result {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
 	// 尝试放水
-	now := time.Now()
+	now := l.clock.Now()
+	l.leak(now)
+
+	result := Result{Limit: l.peakLevel}
+	// 若超过最高水位，请求失败，需要等待到水位足够低
+	if l.currentLevel+float64(n) > float64(l.peakLevel) {
+		result.RetryAfter = l.waitLevel(l.peakLevel - n)
+	} else {
+		// 若没有超过最高水位，当前水位+n，请求成功
+		l.currentLevel += float64(n)
+		result.Allowed = true
+	}
+	// 预留许可时水位可能超过最高水位
+	result.Remaining = maxInt(0, int(math.Floor(float64(l.peakLevel)-l.currentLevel)))
+	result.ResetAfter = l.waitLevel(0)
+	return result
+}
+
+// 放水
+func (l *LeakyBucketLimiter) leak(now time.Time) {
 	// 距离上次放水的时间
 	interval := now.Sub(l.lastTime)
-	if interval >= time.Second {
-		// 当前水位-距离上次放水的时间(秒)*水流速度
-		l.currentLevel = maxInt(0, l.currentLevel-int(interval/time.Second)*l.currentVelocity)
+	if interval > 0 {
+		// 当前水位-距离上次放水的时间(秒)*水流速度，不足一秒的部分也会放水
+		l.currentLevel = math.Max(0, l.currentLevel-interval.Seconds()*l.currentVelocity)
 		l.lastTime = now
 	}
+}
 
-	// 若到达最高水位，请求失败
-	if l.currentLevel >= l.peakLevel {
-		return false
+// 直到水位降到level需要等待的时间，调用前需要先放水
+func (l *LeakyBucketLimiter) waitLevel(level int) time.Duration {
+	if l.currentLevel <= float64(level) {
+		return 0
 	}
-	// 若没有到达最高水位，当前水位+1，请求成功
-	l.currentLevel++
-	return true
+	return secondsToDuration((l.currentLevel - float64(level)) / l.currentVelocity)
 }
 
 func maxInt(a, b int) int {
@@ -1,6 +1,7 @@
 package limiter
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -8,7 +9,7 @@ import (
 func TestNewLeakyBucketLimiter(t *testing.T) {
 	type args struct {
 		peakLevel       int
-		currentVelocity int
+		currentVelocity float64
 	}
 	tests := []struct {
 		name    string
@@ -27,10 +28,11 @@ func TestNewLeakyBucketLimiter(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			l := NewLeakyBucketLimiter(tt.args.peakLevel, tt.args.currentVelocity)
+			clock := NewManualClock(time.Unix(0, 0))
+			l := NewLeakyBucketLimiter(tt.args.peakLevel, tt.args.currentVelocity, WithClock(clock))
 			successCount := 0
 			for i := 0; i < tt.args.peakLevel; i++ {
-				if l.TryAcquire() {
+				if l.TryAcquire(context.Background(), "test") == nil {
 					successCount++
 				}
 			}
@@ -39,17 +41,30 @@ func TestNewLeakyBucketLimiter(t *testing.T) {
 				return
 			}
 
+			// 水连续流出，每100ms流出一个水位，因此除了第一次都能成功
 			successCount = 0
 			for i := 0; i < tt.args.peakLevel; i++ {
-				if l.TryAcquire() {
+				if l.TryAcquire(context.Background(), "test") == nil {
 					successCount++
 				}
-				time.Sleep(time.Second / 10)
+				clock.Advance(time.Second / 10)
 			}
-			if successCount != tt.args.peakLevel-tt.args.currentVelocity {
-				t.Errorf("NewLeakyBucketLimiter() got = %v, want %v", successCount, tt.args.peakLevel-tt.args.currentVelocity)
+			if successCount != tt.args.peakLevel-1 {
+				t.Errorf("NewLeakyBucketLimiter() got = %v, want %v", successCount, tt.args.peakLevel-1)
 				return
 			}
 		})
 	}
 }
+
+func TestLeakyBucketLimiterSubSecondVelocity(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewLeakyBucketLimiter(1, 0.5, WithClock(clock))
+	want := []bool{true, false, true, false}
+	for i := range want {
+		if got := l.TryAcquire(context.Background(), "test") == nil; got != want[i] {
+			t.Errorf("TryAcquire() %d got = %v, want %v", i, got, want[i])
+		}
+		clock.Advance(time.Second)
+	}
+}
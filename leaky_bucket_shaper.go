@@ -0,0 +1,101 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// LeakyBucketShaper 漏桶整形器，和LeakyBucketLimiter只拒绝超过最高水位的请求不同，请求会在漏桶中排队，按水流速度均匀地放行
+// 水位是排队中和正在放行的许可数量，超过最高水位时请求失败，n个许可占用n个放行间隔
+// 放行时间相对于漏桶开始排队的时间计算，因此不会累积取整误差
+type LeakyBucketShaper struct {
+	peakLevel       int        // 最高水位
+	currentVelocity float64    // 水流速度/秒
+	startTime       time.Time  // 漏桶开始排队的时间，漏桶空了之后重新开始
+	scheduled       float64    // 从开始排队到现在已经安排放行的许可数量
+	clock           Clock      // 时钟
+	mutex           sync.Mutex // 避免并发问题
+}
+
+func NewLeakyBucketShaper(peakLevel int, currentVelocity float64, opts ...Option) *LeakyBucketShaper {
+	o := newOptions(opts)
+	return &LeakyBucketShaper{
+		peakLevel:       peakLevel,
+		currentVelocity: currentVelocity,
+		startTime:       o.clock.Now(),
+		clock:           o.clock,
+	}
+}
+
+// Take 阻塞直到n个许可被放行，漏桶满了返回ErrAcquireFailed，n不能超过最高水位
+func (s *LeakyBucketShaper) Take(n int) error {
+	return s.TakeContext(context.Background(), n)
+}
+
+// TakeContext 阻塞直到n个许可被放行，或者ctx结束，ctx结束时归还排队的位置
+// 漏桶满了返回ErrAcquireFailed，预计放行时间超过ctx的截止时间返回ErrWaitExceedsDeadline，这两种情况都不会排队
+func (s *LeakyBucketShaper) TakeContext(ctx context.Context, n int) error {
+	if err := checkPermits(n, s.peakLevel); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// ctx的截止时间总是基于系统时间
+	maxDelay := time.Duration(math.MaxInt64)
+	if deadline, ok := ctx.Deadline(); ok {
+		maxDelay = time.Until(deadline)
+	}
+	delay, startTime, scheduled, err := s.reserve(n, maxDelay)
+	if err != nil || delay <= 0 {
+		return err
+	}
+	timer := s.clock.NewTimer(delay)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+		s.cancel(n, startTime, scheduled)
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// 安排n个许可的放行时间，返回需要等待的时间，以及用于归还位置的开始排队时间和安排之后的许可数量
+func (s *LeakyBucketShaper) reserve(n int, maxDelay time.Duration) (time.Duration, time.Time, float64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.clock.Now()
+	// 当前水位，即还没有放行完的许可数量
+	level := s.scheduled - now.Sub(s.startTime).Seconds()*s.currentVelocity
+	// 漏桶空了，从当前时间重新开始排队
+	if level <= 0 {
+		s.startTime = now
+		s.scheduled = 0
+		level = 0
+	}
+	// 若超过最高水位，请求失败
+	if level+float64(n) > float64(s.peakLevel) {
+		return 0, time.Time{}, 0, ErrAcquireFailed
+	}
+	// 放行时间是前面的许可都放行完的时间
+	delay := s.startTime.Add(secondsToDuration(s.scheduled / s.currentVelocity)).Sub(now)
+	if delay > maxDelay {
+		return 0, time.Time{}, 0, ErrWaitExceedsDeadline
+	}
+	s.scheduled += float64(n)
+	return delay, s.startTime, s.scheduled, nil
+}
+
+// 归还n个许可排队的位置，只有排在最后时才能归还，否则后面的许可已经安排了放行时间，位置会空出来
+func (s *LeakyBucketShaper) cancel(n int, startTime time.Time, scheduled float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.startTime.Equal(startTime) && s.scheduled == scheduled {
+		s.scheduled -= float64(n)
+	}
+}
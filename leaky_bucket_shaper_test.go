@@ -0,0 +1,86 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLeakyBucketShaper(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	s := NewLeakyBucketShaper(3, 10, WithClock(clock))
+	// 第一个许可立即放行
+	if err := s.Take(1); err != nil {
+		t.Fatalf("Take(1) error = %v", err)
+	}
+	// 后面的许可每100毫秒放行一个
+	done := make(chan int, 2)
+	for i := 1; i <= 2; i++ {
+		i := i
+		go func() {
+			if err := s.Take(1); err != nil {
+				t.Errorf("Take(1) %d error = %v", i, err)
+			}
+			done <- i
+		}()
+		waitFor(t, func() bool { return clock.PendingTimers() == i })
+	}
+	// 漏桶满了
+	if err := s.Take(1); !errors.Is(err, ErrAcquireFailed) {
+		t.Errorf("Take(1) error = %v, want %v", err, ErrAcquireFailed)
+	}
+	for i := 1; i <= 2; i++ {
+		clock.Advance(time.Second/10 - 1)
+		select {
+		case got := <-done:
+			t.Fatalf("released %d before %v", got, clock.Now())
+		case <-time.After(10 * time.Millisecond):
+		}
+		clock.Advance(1)
+		if got := <-done; got != i {
+			t.Errorf("released %d, want %d", got, i)
+		}
+	}
+	// 最后一个许可正在放行，水位是1，不能再排队3个许可
+	if err := s.TakeContext(context.Background(), 3); !errors.Is(err, ErrAcquireFailed) {
+		t.Errorf("TakeContext(3) error = %v, want %v", err, ErrAcquireFailed)
+	}
+}
+
+func TestLeakyBucketShaperTakeContext(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	s := NewLeakyBucketShaper(3, 10, WithClock(clock))
+	if err := s.Take(1); err != nil {
+		t.Fatalf("Take(1) error = %v", err)
+	}
+	// 预计放行时间超过截止时间，不会排队
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := s.TakeContext(ctx, 1); !errors.Is(err, ErrWaitExceedsDeadline) {
+		t.Errorf("TakeContext() error = %v, want %v", err, ErrWaitExceedsDeadline)
+	}
+	// ctx结束时归还排队的位置
+	ctx, cancel = context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.TakeContext(ctx, 2)
+	}()
+	waitFor(t, func() bool { return clock.PendingTimers() == 1 })
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("TakeContext() error = %v, want %v", err, context.Canceled)
+	}
+	// 归还之后还能排队2个许可，并且在100毫秒之后放行
+	go func() {
+		errs <- s.TakeContext(context.Background(), 2)
+	}()
+	waitFor(t, func() bool { return clock.PendingTimers() == 1 })
+	clock.Advance(time.Second / 10)
+	if err := <-errs; err != nil {
+		t.Errorf("TakeContext() error = %v", err)
+	}
+	if err := s.Take(4); !errors.Is(err, ErrPermitsExceedCapacity) {
+		t.Errorf("Take(4) error = %v, want %v", err, ErrPermitsExceedCapacity)
+	}
+}
@@ -0,0 +1,74 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrAcquireFailed 获取失败
+	ErrAcquireFailed = errors.New("acquire failed")
+	// ErrInvalidPermits 许可数量必须大于0
+	ErrInvalidPermits = errors.New("permits must be greater than 0")
+	// ErrPermitsExceedCapacity 许可数量超过了限流器的容量（窗口请求上限、令牌桶容量或者最高水位），永远无法获取
+	ErrPermitsExceedCapacity = errors.New("permits exceed capacity")
+)
+
+// Limiter 限流器
+// 内存限流器和Redis限流器都实现了该接口，因此可以通过配置切换限流算法和存储
+type Limiter interface {
+	// TryAcquire 尝试获取资源的许可，获取失败返回ErrAcquireFailed或ViolationStrategyError
+	TryAcquire(ctx context.Context, resource string) error
+	// TryAcquireN 尝试获取资源的n个许可，要么全部获取，要么都不获取
+	TryAcquireN(ctx context.Context, resource string, n int) error
+	// Wait 阻塞直到获取资源的许可，或者ctx结束，或者预计等待时间超过ctx的截止时间（返回ErrWaitExceedsDeadline）
+	Wait(ctx context.Context, resource string) error
+	// WaitN 阻塞直到获取资源的n个许可，其他同Wait
+	WaitN(ctx context.Context, resource string, n int) error
+	// Allow 尝试获取资源的n个许可，返回剩余许可、重置时间和等待时间，获取失败时Result.Allowed为false，不返回错误
+	Allow(ctx context.Context, resource string, n int) (*Result, error)
+}
+
+// PartialLimiter 支持部分获取的限流器，适用于根据剩余许可决定批次大小的场景
+type PartialLimiter interface {
+	// TryAcquireUpTo 尝试获取资源最多n个许可，原子地返回实际获取的许可数量
+	TryAcquireUpTo(ctx context.Context, resource string, n int) (int, error)
+}
+
+var (
+	_ Limiter = (*FixedWindowLimiter)(nil)
+	_ Limiter = (*SlidingWindowLimiter)(nil)
+	_ Limiter = (*SlidingLogLimiter)(nil)
+	_ Limiter = (*TokenBucketLimiter)(nil)
+	_ Limiter = (*LeakyBucketLimiter)(nil)
+	_ Limiter = (*KeyedLimiter)(nil)
+	_ Limiter = (*AtomicTokenBucketLimiter)(nil)
+	_ Limiter = (*AtomicFixedWindowLimiter)(nil)
+	_ Limiter = (*ShardedTokenBucketLimiter)(nil)
+	_ Limiter = (*GCRALimiter)(nil)
+	_ Limiter = (*SlidingWindowCounterLimiter)(nil)
+	_ Limiter = (*ExactSlidingLogLimiter)(nil)
+	_ Limiter = (*CalendarWindowLimiter)(nil)
+
+	_ PartialLimiter = (*FixedWindowLimiter)(nil)
+	_ PartialLimiter = (*SlidingWindowLimiter)(nil)
+	_ PartialLimiter = (*TokenBucketLimiter)(nil)
+	_ PartialLimiter = (*AtomicTokenBucketLimiter)(nil)
+	_ PartialLimiter = (*AtomicFixedWindowLimiter)(nil)
+	_ PartialLimiter = (*ShardedTokenBucketLimiter)(nil)
+	_ PartialLimiter = (*GCRALimiter)(nil)
+	_ PartialLimiter = (*SlidingWindowCounterLimiter)(nil)
+	_ PartialLimiter = (*ExactSlidingLogLimiter)(nil)
+	_ PartialLimiter = (*CalendarWindowLimiter)(nil)
+)
+
+// 检查许可数量是否合法
+func checkPermits(n, capacity int) error {
+	if n < 1 {
+		return ErrInvalidPermits
+	}
+	if n > capacity {
+		return ErrPermitsExceedCapacity
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTryAcquireN(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	slidingWindowLimiter, _ := NewSlidingWindowLimiter(10, time.Second, time.Second/10, WithClock(clock))
+	slidingLogLimiter, _ := NewSlidingLogLimiter(time.Second/10, []*SlidingLogLimiterStrategy{
+		NewSlidingLogLimiterStrategy(10, time.Second), NewSlidingLogLimiterStrategy(100, time.Minute),
+	}, WithClock(clock))
+	tokenBucketLimiter := NewTokenBucketLimiter(10, 10, WithClock(clock))
+	atomicTokenBucketLimiter := NewAtomicTokenBucketLimiter(10, 10, WithClock(clock))
+	atomicFixedWindowLimiter, _ := NewAtomicFixedWindowLimiter(10, time.Second, WithClock(clock))
+	calendarWindowLimiter, _ := NewCalendarWindowLimiter(10, CalendarDay, time.UTC, WithClock(clock))
+	// 令牌桶初始没有令牌，等待令牌发放
+	clock.Advance(time.Second)
+	tests := []struct {
+		name    string
+		limiter Limiter
+	}{
+		{name: "fixed_window", limiter: NewFixedWindowLimiter(10, time.Second, WithClock(clock))},
+		{name: "sliding_window", limiter: slidingWindowLimiter},
+		{name: "sliding_log", limiter: slidingLogLimiter},
+		{name: "token_bucket", limiter: tokenBucketLimiter},
+		{name: "leaky_bucket", limiter: NewLeakyBucketLimiter(10, 10, WithClock(clock))},
+		{name: "atomic_fixed_window", limiter: atomicFixedWindowLimiter},
+		{name: "atomic_token_bucket", limiter: atomicTokenBucketLimiter},
+		{name: "gcra", limiter: NewGCRALimiter(10, 10, WithClock(clock))},
+		{name: "sliding_window_counter", limiter: NewSlidingWindowCounterLimiter(10, time.Second, WithClock(clock))},
+		{name: "exact_sliding_log", limiter: NewExactSlidingLogLimiter(10, time.Second, WithClock(clock))},
+		{name: "calendar_window", limiter: calendarWindowLimiter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := []struct {
+				n       int
+				wantErr bool
+			}{
+				{n: 7},
+				// 要么全部获取，要么都不获取
+				{n: 4, wantErr: true},
+				{n: 3},
+				{n: 1, wantErr: true},
+			}
+			for _, step := range steps {
+				err := tt.limiter.TryAcquireN(context.Background(), "test", step.n)
+				if (err != nil) != step.wantErr {
+					t.Errorf("TryAcquireN(%d) error = %v, wantErr %v", step.n, err, step.wantErr)
+				}
+			}
+			if err := tt.limiter.TryAcquireN(context.Background(), "test", 11); !errors.Is(err, ErrPermitsExceedCapacity) {
+				t.Errorf("TryAcquireN(11) error = %v, want %v", err, ErrPermitsExceedCapacity)
+			}
+			if err := tt.limiter.TryAcquireN(context.Background(), "test", 0); !errors.Is(err, ErrInvalidPermits) {
+				t.Errorf("TryAcquireN(0) error = %v, want %v", err, ErrInvalidPermits)
+			}
+		})
+	}
+}
+
+func TestTryAcquireUpTo(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	slidingWindowLimiter, _ := NewSlidingWindowLimiter(10, time.Second, time.Second/10, WithClock(clock))
+	tokenBucketLimiter := NewTokenBucketLimiter(10, 10, WithClock(clock))
+	atomicTokenBucketLimiter := NewAtomicTokenBucketLimiter(10, 10, WithClock(clock))
+	atomicFixedWindowLimiter, _ := NewAtomicFixedWindowLimiter(10, time.Second, WithClock(clock))
+	calendarWindowLimiter, _ := NewCalendarWindowLimiter(10, CalendarDay, time.UTC, WithClock(clock))
+	// 令牌桶初始没有令牌，等待令牌发放
+	clock.Advance(time.Second)
+	tests := []struct {
+		name    string
+		limiter PartialLimiter
+	}{
+		{name: "fixed_window", limiter: NewFixedWindowLimiter(10, time.Second, WithClock(clock))},
+		{name: "sliding_window", limiter: slidingWindowLimiter},
+		{name: "token_bucket", limiter: tokenBucketLimiter},
+		{name: "atomic_fixed_window", limiter: atomicFixedWindowLimiter},
+		{name: "atomic_token_bucket", limiter: atomicTokenBucketLimiter},
+		{name: "gcra", limiter: NewGCRALimiter(10, 10, WithClock(clock))},
+		{name: "sliding_window_counter", limiter: NewSlidingWindowCounterLimiter(10, time.Second, WithClock(clock))},
+		{name: "exact_sliding_log", limiter: NewExactSlidingLogLimiter(10, time.Second, WithClock(clock))},
+		{name: "calendar_window", limiter: calendarWindowLimiter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := []struct {
+				n    int
+				want int
+			}{
+				{n: 7, want: 7},
+				// 只获取剩余的许可
+				{n: 7, want: 3},
+				{n: 1, want: 0},
+			}
+			for _, step := range steps {
+				got, err := tt.limiter.TryAcquireUpTo(context.Background(), "test", step.n)
+				if err != nil || got != step.want {
+					t.Errorf("TryAcquireUpTo(%d) = %v, %v, want %v", step.n, got, err, step.want)
+				}
+			}
+			if _, err := tt.limiter.TryAcquireUpTo(context.Background(), "test", 0); !errors.Is(err, ErrInvalidPermits) {
+				t.Errorf("TryAcquireUpTo(0) error = %v, want %v", err, ErrInvalidPermits)
+			}
+		})
+	}
+}
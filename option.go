@@ -0,0 +1,25 @@
+package limiter
+
+// Option 限流器选项
+type Option func(*options)
+
+type options struct {
+	clock Clock // 时钟
+}
+
+// WithClock 设置限流器使用的时钟，默认是SystemClock
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		clock: SystemClock,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
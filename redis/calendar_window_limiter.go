@@ -0,0 +1,162 @@
+package redis
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
+	"time"
+)
+
+const calendarWindowLimiterTryAcquireRedisScript = `
+-- ARGV[1]: 当前窗口的开始时间（毫秒）
+-- ARGV[2]: 当前窗口的结束时间（毫秒）
+-- ARGV[3]: 窗口请求上限
+-- ARGV[4]: 当前时间（毫秒）
+-- ARGV[5]: 许可数量
+-- ARGV[6]: 是否部分获取，部分获取时只获取剩余的许可
+-- 返回获取的许可数量、失败时需要等待的时间、剩余许可数量和窗口结束的时间
+-- 日历边界和时区由客户端计算，脚本只保存当前窗口的开始时间、结束时间和计数器
+
+local start = tonumber(ARGV[1])
+local finish = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+local permits = tonumber(ARGV[5])
+local partial = tonumber(ARGV[6]) == 1
+
+-- 如果还在保存的窗口内，使用保存的计数器，否则进入新的窗口，计数器清0
+-- 客户端时钟落后时继续使用保存的更新的窗口
+local state = redis.call("hmget", KEYS[1], "start", "end", "counter")
+local last = tonumber(state[1])
+local counter = 0
+if last ~= nil and last >= start then
+	start = last
+	finish = tonumber(state[2])
+	counter = tonumber(state[3])
+end
+local resetAfter = math.max(1, finish - now)
+
+if partial and counter + permits > limit and counter < limit then
+	permits = limit - counter
+end
+-- 若超过窗口请求上限，请求失败，需要等待到窗口结束
+if counter + permits > limit then
+	return {0, resetAfter, limit - counter, resetAfter}
+end
+-- 若没超过窗口请求上限，计数器+许可数量，请求成功，窗口结束之后不再需要计数器
+counter = counter + permits
+redis.call("hmset", KEYS[1], "start", start, "end", finish, "counter", counter)
+redis.call("pexpire", KEYS[1], resetAfter)
+return {permits, 0, limit - counter, resetAfter}
+`
+
+// CalendarWindowLimiter 日历窗口限流器，窗口按时区的日历边界对齐，同limiter.CalendarWindowLimiter
+// 日历边界需要时区信息，脚本无法计算，因此总是使用时钟计算当前时间，不支持WithServerTime
+type CalendarWindowLimiter struct {
+	limit    int                  // 窗口请求上限
+	unit     limiter.CalendarUnit // 窗口单位
+	location *time.Location       // 计算日历边界的时区
+	client   redis.Scripter       // Redis客户端
+	script   *redis.Script        // TryAcquire脚本
+	clock    limiter.Clock        // 时钟
+}
+
+func NewCalendarWindowLimiter(client redis.Scripter, limit int, unit limiter.CalendarUnit, location *time.Location,
+	opts ...Option) (*CalendarWindowLimiter, error) {
+	if !unit.Valid() {
+		return nil, ErrInvalidCalendarUnit
+	}
+	o := newOptions(opts)
+	// 使用Redis服务器时间时无法计算时区的日历边界，客户端时钟不一致时窗口边界会有误差
+	if o.serverTime {
+		return nil, ErrServerTimeNotSupported
+	}
+
+	return &CalendarWindowLimiter{
+		limit:    limit,
+		unit:     unit,
+		location: location,
+		client:   client,
+		script:   redis.NewScript(calendarWindowLimiterTryAcquireRedisScript),
+		clock:    o.clock,
+	}, nil
+}
+
+// TryAcquire 尝试获取许可
+func (l *CalendarWindowLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过窗口请求上限
+func (l *CalendarWindowLimiter) TryAcquireN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(ctx, resource, n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *CalendarWindowLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *CalendarWindowLimiter) WaitN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	return limiter.Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(ctx, resource, n)
+	})
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，原子地返回实际获取的许可数量，没有剩余许可时返回0
+func (l *CalendarWindowLimiter) TryAcquireUpTo(ctx context.Context, resource string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	result, err := l.run(ctx, resource, n, 1)
+	if err != nil {
+		return 0, err
+	}
+	return int(result[0]), nil
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过窗口请求上限
+func (l *CalendarWindowLimiter) Allow(ctx context.Context, resource string, n int) (*Result, error) {
+	if err := checkPermits(n, l.limit); err != nil {
+		return nil, err
+	}
+	return l.acquire(ctx, resource, n)
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *CalendarWindowLimiter) tryAcquire(ctx context.Context, resource string, n int) (time.Duration, error) {
+	result, err := l.acquire(ctx, resource, n)
+	if err != nil {
+		return 0, err
+	}
+	// 若到达窗口请求上限，请求失败，需要等待到窗口结束
+	if !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *CalendarWindowLimiter) acquire(ctx context.Context, resource string, n int) (*Result, error) {
+	values, err := l.run(ctx, resource, n, 0)
+	if err != nil {
+		return nil, err
+	}
+	return newResult(l.limit, values), nil
+}
+
+// 计算当前时间所在的窗口，执行脚本
+func (l *CalendarWindowLimiter) run(ctx context.Context, resource string, n, partial int) ([]int64, error) {
+	now := l.clock.Now()
+	start, end := l.unit.Window(now, l.location)
+	return l.script.Run(ctx, l.client, []string{resource},
+		start.UnixMilli(), end.UnixMilli(), l.limit, now.UnixMilli(), n, partial).Int64Slice()
+}
@@ -0,0 +1,74 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
+	"testing"
+	"time"
+)
+
+func TestCalendarWindowLimiter(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	// 使用单独的资源，避免和其他测试保存的不同类型的值冲突
+	const resource = "test_calendar_window"
+	client.Del(context.Background(), resource)
+	t.Cleanup(func() {
+		client.Del(context.Background(), resource)
+	})
+	shanghai := time.FixedZone("CST", 8*60*60)
+	// 从当前时间所在的上海时间的23点开始
+	year, month, day := time.Now().In(shanghai).Date()
+	clock := limiter.NewManualClock(time.Date(year, month, day, 23, 0, 0, 0, shanghai))
+	l, _ := NewCalendarWindowLimiter(client, 2, limiter.CalendarDay, shanghai, WithClock(clock))
+	steps := []struct {
+		advance time.Duration // 请求之前经过的时间
+		want    Result
+	}{
+		{want: Result{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Hour}},
+		{advance: time.Minute, want: Result{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Hour - time.Minute}},
+		// 需要等待到零点
+		{advance: time.Minute,
+			want: Result{Limit: 2, Remaining: 0, ResetAfter: time.Hour - 2*time.Minute, RetryAfter: time.Hour - 2*time.Minute}},
+		// 零点重置，和第一个请求的时间无关
+		{advance: time.Hour - 2*time.Minute, want: Result{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: 24 * time.Hour}},
+	}
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		got, err := l.Allow(context.Background(), resource, 1)
+		if err != nil || *got != step.want {
+			t.Errorf("%d Allow() = %+v, %v, want %+v", i, got, err, step.want)
+		}
+	}
+	// 窗口结束之后不再需要计数器
+	if ttl := client.PTTL(context.Background(), resource).Val(); ttl <= 0 || ttl > 24*time.Hour {
+		t.Errorf("PTTL() = %v, want (0, %v]", ttl, 24*time.Hour)
+	}
+}
+
+func TestNewCalendarWindowLimiter(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	tests := []struct {
+		name    string
+		unit    limiter.CalendarUnit
+		opts    []Option
+		wantErr error
+	}{
+		{name: "day", unit: limiter.CalendarDay},
+		{name: "invalid_unit", unit: limiter.CalendarMonth + 1, wantErr: ErrInvalidCalendarUnit},
+		// 使用Redis服务器时间时无法计算时区的日历边界
+		{name: "server_time", unit: limiter.CalendarDay, opts: []Option{WithServerTime()}, wantErr: ErrServerTimeNotSupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCalendarWindowLimiter(client, 10, tt.unit, time.UTC, tt.opts...); !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewCalendarWindowLimiter() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,6 +1,35 @@
 package redis
 
-import "errors"
+import (
+	"errors"
+	"github.com/jiaxwu/limiter"
+)
 
-// ErrAcquireFailed 获取失败
-var ErrAcquireFailed = errors.New("acquire failed")
+// 与内存限流器共用同一组错误，方便切换存储
+var (
+	// ErrAcquireFailed 获取失败
+	ErrAcquireFailed = limiter.ErrAcquireFailed
+	// ErrInvalidPermits 许可数量必须大于0
+	ErrInvalidPermits = limiter.ErrInvalidPermits
+	// ErrPermitsExceedCapacity 许可数量超过了限流器的容量，永远无法获取
+	ErrPermitsExceedCapacity = limiter.ErrPermitsExceedCapacity
+	// ErrInvalidCalendarUnit 日历窗口的单位无效
+	ErrInvalidCalendarUnit = limiter.ErrInvalidCalendarUnit
+)
+
+// ErrServerTimeNotSupported 限流器不支持使用Redis服务器时间
+var ErrServerTimeNotSupported = errors.New("server time is not supported by the limiter")
+
+// ViolationStrategyError 违背策略错误
+type ViolationStrategyError = limiter.ViolationStrategyError
+
+// 检查许可数量是否合法
+func checkPermits(n, capacity int) error {
+	if n < 1 {
+		return ErrInvalidPermits
+	}
+	if n > capacity {
+		return ErrPermitsExceedCapacity
+	}
+	return nil
+}
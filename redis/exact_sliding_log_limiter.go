@@ -0,0 +1,185 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const exactSlidingLogLimiterTryAcquireRedisScript = currentTimeRedisScript + `
+-- ARGV[1]: 窗口时间大小
+-- ARGV[2]: 窗口请求上限
+-- ARGV[3]: 当前时间（毫秒），负数时使用Redis服务器时间
+-- ARGV[4]: 许可数量
+-- ARGV[5]: 是否部分获取，部分获取时只获取剩余的许可
+-- ARGV[6]: 请求的唯一标识，避免同一毫秒内不同请求的成员相同
+-- 返回获取的许可数量、失败时需要等待的时间、剩余许可数量和最新的许可过期的时间
+-- 每个许可是有序集合的一个成员，分数是获取时间
+
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local now = currentTime(tonumber(ARGV[3]))
+local permits = tonumber(ARGV[4])
+local partial = tonumber(ARGV[5]) == 1
+local nonce = ARGV[6]
+
+-- 删除已经过期的许可，获取时间+窗口时间大小不晚于当前时间时过期
+redis.call("zremrangebyscore", KEYS[1], "-inf", now - window)
+local count = redis.call("zcard", KEYS[1])
+
+if partial and count + permits > limit and count < limit then
+	permits = limit - count
+end
+
+-- 若超过窗口请求上限，请求失败，需要等待到最早的count+permits-limit个许可过期
+-- 部分获取时permits可能超过窗口请求上限，最多等待到所有许可过期
+if count + permits > limit then
+	local index = math.min(count + permits - limit, count) - 1
+	local expired = redis.call("zrange", KEYS[1], index, index, "withscores")
+	local retryAfter = 0
+	if #(expired) > 0 then
+		retryAfter = tonumber(expired[2]) + window - now
+	end
+	local newest = redis.call("zrange", KEYS[1], -1, -1, "withscores")
+	local resetAfter = 0
+	if #(newest) > 0 then
+		resetAfter = tonumber(newest[2]) + window - now
+	end
+	return {0, retryAfter, limit - count, resetAfter}
+end
+
+-- 若没超过窗口请求上限，记录每个许可的获取时间，请求成功
+for i = 1, permits do
+	redis.call("zadd", KEYS[1], now, now .. ":" .. nonce .. ":" .. i)
+end
+-- 最新的许可过期之后集合为空，因此可以过期，客户端时钟不一致时最新的许可可能晚于当前时间
+local newest = redis.call("zrange", KEYS[1], -1, -1, "withscores")
+local resetAfter = tonumber(newest[2]) + window - now
+redis.call("pexpire", KEYS[1], resetAfter)
+return {permits, 0, limit - count - permits, resetAfter}
+`
+
+// ExactSlidingLogLimiter 精确滑动日志限流器，使用有序集合记录每个许可的获取时间，适用于请求量小但需要精确限流的场景
+type ExactSlidingLogLimiter struct {
+	sequence   uint64         // 请求序号，和随机前缀组成请求的唯一标识，放在开头保证32位平台上原子操作的对齐
+	limit      int            // 窗口请求上限
+	window     int64          // 窗口时间大小
+	client     redis.Scripter // Redis客户端
+	script     *redis.Script  // TryAcquire脚本
+	clock      limiter.Clock  // 时钟
+	serverTime bool           // 是否使用Redis服务器时间
+	prefix     string         // 随机前缀，区分不同进程和不同限流器的请求
+}
+
+func NewExactSlidingLogLimiter(client redis.Scripter, limit int, window time.Duration, opts ...Option) (
+	*ExactSlidingLogLimiter, error) {
+	// redis过期时间精度最大到毫秒，因此窗口必须能被毫秒整除
+	if window%time.Millisecond != 0 {
+		return nil, errors.New("the window uint must not be less than millisecond")
+	}
+
+	// 不同进程的math/rand可能使用相同的种子，因此使用crypto/rand生成随机前缀
+	prefix := make([]byte, 8)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	return &ExactSlidingLogLimiter{
+		limit:      limit,
+		window:     int64(window / time.Millisecond),
+		client:     client,
+		script:     redis.NewScript(exactSlidingLogLimiterTryAcquireRedisScript),
+		clock:      o.clock,
+		serverTime: o.serverTime,
+		prefix:     hex.EncodeToString(prefix),
+	}, nil
+}
+
+// TryAcquire 尝试获取许可
+func (l *ExactSlidingLogLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过窗口请求上限
+func (l *ExactSlidingLogLimiter) TryAcquireN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(ctx, resource, n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *ExactSlidingLogLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *ExactSlidingLogLimiter) WaitN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	return limiter.Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(ctx, resource, n)
+	})
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，原子地返回实际获取的许可数量，没有剩余许可时返回0
+func (l *ExactSlidingLogLimiter) TryAcquireUpTo(ctx context.Context, resource string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	// 当前时间，使用Redis服务器时间时由脚本获取
+	now := scriptNow(l.clock, l.serverTime)
+	result, err := l.script.Run(
+		ctx, l.client, []string{resource}, l.window, l.limit, now, n, 1, l.nonce()).Int64Slice()
+	if err != nil {
+		return 0, err
+	}
+	return int(result[0]), nil
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过窗口请求上限
+func (l *ExactSlidingLogLimiter) Allow(ctx context.Context, resource string, n int) (*Result, error) {
+	if err := checkPermits(n, l.limit); err != nil {
+		return nil, err
+	}
+	return l.acquire(ctx, resource, n)
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *ExactSlidingLogLimiter) tryAcquire(ctx context.Context, resource string, n int) (time.Duration, error) {
+	result, err := l.acquire(ctx, resource, n)
+	if err != nil {
+		return 0, err
+	}
+	// 若到达窗口请求上限，请求失败，需要等待到足够多的许可过期
+	if !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *ExactSlidingLogLimiter) acquire(ctx context.Context, resource string, n int) (*Result, error) {
+	// 当前时间，使用Redis服务器时间时由脚本获取
+	now := scriptNow(l.clock, l.serverTime)
+	values, err := l.script.Run(
+		ctx, l.client, []string{resource}, l.window, l.limit, now, n, 0, l.nonce()).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return newResult(l.limit, values), nil
+}
+
+// 请求的唯一标识，随机前缀+递增的请求序号，在不同进程和不同请求之间都不会重复
+func (l *ExactSlidingLogLimiter) nonce() string {
+	return l.prefix + strconv.FormatUint(atomic.AddUint64(&l.sequence, 1), 10)
+}
@@ -0,0 +1,83 @@
+package redis
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
+	"testing"
+	"time"
+)
+
+func TestExactSlidingLogLimiter(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	// 使用单独的资源，避免和其他测试保存的不同类型的值冲突
+	const resource = "test_exact_sliding_log"
+	client.Del(context.Background(), resource)
+	t.Cleanup(func() {
+		client.Del(context.Background(), resource)
+	})
+	clock := limiter.NewManualClock(time.Now())
+	l, _ := NewExactSlidingLogLimiter(client, 3, time.Second, WithClock(clock))
+	steps := []struct {
+		advance time.Duration // 请求之前经过的时间
+		n       int
+		want    Result
+	}{
+		{n: 1, want: Result{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: time.Second}},
+		{advance: time.Second * 4 / 10, n: 2, want: Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: time.Second}},
+		// 需要等待到第一个许可过期
+		{advance: time.Second * 2 / 10, n: 1,
+			want: Result{Limit: 3, Remaining: 0, ResetAfter: time.Second * 8 / 10, RetryAfter: time.Second * 4 / 10}},
+		// 第一个许可刚好过期
+		{advance: time.Second * 4 / 10, n: 1, want: Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: time.Second}},
+		{n: 1, want: Result{Limit: 3, Remaining: 0, ResetAfter: time.Second, RetryAfter: time.Second * 4 / 10}},
+	}
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		got, err := l.Allow(context.Background(), resource, step.n)
+		if err != nil || *got != step.want {
+			t.Errorf("Allow(%d) %d = %+v, %v, want %+v", step.n, i, got, err, step.want)
+		}
+	}
+	// 部分获取的许可数量可以超过窗口请求上限，没有剩余许可时返回0
+	if got, err := l.TryAcquireUpTo(context.Background(), resource, 5); err != nil || got != 0 {
+		t.Errorf("TryAcquireUpTo(5) = %v, %v, want %v", got, err, 0)
+	}
+	// 每个许可是有序集合的一个成员
+	if count := client.ZCard(context.Background(), resource).Val(); count != 3 {
+		t.Errorf("ZCard() = %v, want %v", count, 3)
+	}
+}
+
+func TestExactSlidingLogLimiterMembers(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	// 使用单独的资源，避免和其他测试保存的不同类型的值冲突
+	const resource = "test_exact_sliding_log_members"
+	client.Del(context.Background(), resource)
+	t.Cleanup(func() {
+		client.Del(context.Background(), resource)
+	})
+	// 两个限流器模拟两个进程，在同一毫秒获取许可
+	clock := limiter.NewManualClock(time.Now())
+	l1, _ := NewExactSlidingLogLimiter(client, 4, time.Second, WithClock(clock))
+	l2, _ := NewExactSlidingLogLimiter(client, 4, time.Second, WithClock(clock))
+	if l1.nonce() == l2.nonce() {
+		t.Errorf("nonce() of different limiters are equal")
+	}
+	for _, l := range []*ExactSlidingLogLimiter{l1, l2, l1, l2} {
+		if err := l.TryAcquire(context.Background(), resource); err != nil {
+			t.Errorf("TryAcquire() error = %v", err)
+		}
+	}
+	// 每个许可都是不同的成员，不会互相覆盖
+	if count := client.ZCard(context.Background(), resource).Val(); count != 4 {
+		t.Errorf("ZCard() = %v, want %v", count, 4)
+	}
+	if err := l1.TryAcquire(context.Background(), resource); err == nil {
+		t.Errorf("TryAcquire() error = nil, want error")
+	}
+}
@@ -4,42 +4,57 @@ import (
 	"context"
 	"errors"
 	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
 	"time"
 )
 
 const fixedWindowLimiterTryAcquireRedisScript = `
 -- ARGV[1]: 窗口时间大小
 -- ARGV[2]: 窗口请求上限
+-- ARGV[3]: 许可数量
+-- ARGV[4]: 是否部分获取，部分获取时只获取剩余的许可
+-- 返回获取的许可数量、失败时需要等待的时间、剩余许可数量和窗口过期时间
 
 local window = tonumber(ARGV[1])
 local limit = tonumber(ARGV[2])
+local permits = tonumber(ARGV[3])
+local partial = tonumber(ARGV[4]) == 1
 
 -- 获取原始值
 local counter = tonumber(redis.call("get", KEYS[1]))
 if counter == nil then 
 	counter = 0
 end
--- 若到达窗口请求上限，请求失败
-if counter >= limit then
-	return 0
+if partial and counter + permits > limit and counter < limit then
+	permits = limit - counter
 end
--- 窗口值+1
-redis.call("incr", KEYS[1])
+-- 若超过窗口请求上限，请求失败，需要等待到窗口过期
+if counter + permits > limit then
+	local ttl = redis.call("pttl", KEYS[1])
+	if ttl < 0 then
+		ttl = window
+	end
+	return {0, ttl, limit - counter, ttl}
+end
+-- 窗口值+许可数量
+redis.call("incrby", KEYS[1], permits)
 if counter == 0 then
     redis.call("pexpire", KEYS[1], window)
 end
-return 1
+return {permits, 0, limit - counter - permits, redis.call("pttl", KEYS[1])}
 `
 
 // FixedWindowLimiter 固定窗口限流器
 type FixedWindowLimiter struct {
-	limit  int           // 窗口请求上限
-	window int           // 窗口时间大小
-	client *redis.Client // Redis客户端
-	script *redis.Script // TryAcquire脚本
+	limit  int            // 窗口请求上限
+	window int            // 窗口时间大小
+	client redis.Scripter // Redis客户端
+	script *redis.Script  // TryAcquire脚本
+	clock  limiter.Clock  // 时钟
 }
 
-func NewFixedWindowLimiter(client *redis.Client, limit int, window time.Duration) (*FixedWindowLimiter, error) {
+func NewFixedWindowLimiter(client redis.Scripter, limit int, window time.Duration, opts ...Option) (
+	*FixedWindowLimiter, error) {
 	// redis过期时间精度最大到毫秒，因此窗口必须能被毫秒整除
 	if window%time.Millisecond != 0 {
 		return nil, errors.New("the window uint must not be less than millisecond")
@@ -50,17 +65,77 @@ func NewFixedWindowLimiter(client *redis.Client, limit int, window time.Duration
 		window: int(window / time.Millisecond),
 		client: client,
 		script: redis.NewScript(fixedWindowLimiterTryAcquireRedisScript),
+		clock:  newOptions(opts).clock,
 	}, nil
 }
 
+// TryAcquire 尝试获取许可
 func (l *FixedWindowLimiter) TryAcquire(ctx context.Context, resource string) error {
-	success, err := l.script.Run(ctx, l.client, []string{resource}, l.window, l.limit).Bool()
-	if err != nil {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过窗口请求上限
+func (l *FixedWindowLimiter) TryAcquireN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
 		return err
 	}
+	_, err := l.tryAcquire(ctx, resource, n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *FixedWindowLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *FixedWindowLimiter) WaitN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	return limiter.Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(ctx, resource, n)
+	})
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，原子地返回实际获取的许可数量，没有剩余许可时返回0
+func (l *FixedWindowLimiter) TryAcquireUpTo(ctx context.Context, resource string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	result, err := l.script.Run(ctx, l.client, []string{resource}, l.window, l.limit, n, 1).Int64Slice()
+	if err != nil {
+		return 0, err
+	}
+	return int(result[0]), nil
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过窗口请求上限
+func (l *FixedWindowLimiter) Allow(ctx context.Context, resource string, n int) (*Result, error) {
+	if err := checkPermits(n, l.limit); err != nil {
+		return nil, err
+	}
+	return l.acquire(ctx, resource, n)
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *FixedWindowLimiter) tryAcquire(ctx context.Context, resource string, n int) (time.Duration, error) {
+	result, err := l.acquire(ctx, resource, n)
+	if err != nil {
+		return 0, err
+	}
 	// 若到达窗口请求上限，请求失败
-	if !success {
-		return ErrAcquireFailed
+	if !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *FixedWindowLimiter) acquire(ctx context.Context, resource string, n int) (*Result, error) {
+	values, err := l.script.Run(ctx, l.client, []string{resource}, l.window, l.limit, n, 0).Int64Slice()
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return newResult(l.limit, values), nil
 }
@@ -0,0 +1,149 @@
+package redis
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
+	"time"
+)
+
+const gcraLimiterTryAcquireRedisScript = currentTimeRedisScript + `
+-- ARGV[1]: 容量
+-- ARGV[2]: 发放一个令牌的时间（毫秒），可能是小数
+-- ARGV[3]: 当前时间（毫秒），负数时使用Redis服务器时间
+-- ARGV[4]: 许可数量
+-- ARGV[5]: 是否部分获取，部分获取时只获取剩余的令牌
+-- 返回获取的许可数量、需要等待的时间、剩余许可数量和令牌发放满需要的时间（毫秒）
+-- 只保存理论到达时间（毫秒），即令牌发放满的时间，保留到微秒并向下取整
+
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local now = currentTime(tonumber(ARGV[3]))
+local permits = tonumber(ARGV[4])
+local partial = tonumber(ARGV[5]) == 1
+-- 允许的突发，即发放容量个令牌的时间
+local tolerance = capacity * interval
+-- 浮点数误差，避免整数个令牌被计算为略小于整数
+local epsilon = 1e-6
+
+-- 理论到达时间早于当前时间时令牌是满的，从当前时间开始计算
+local tat = tonumber(redis.call("get", KEYS[1]))
+if tat == nil or tat < now then
+	tat = now
+end
+
+-- 剩余的令牌数量
+local remaining = math.max(0, math.floor((tolerance - (tat - now)) / interval + epsilon))
+if partial and permits > remaining and remaining >= 1 then
+	permits = remaining
+end
+-- 获取之后的理论到达时间，往前推容量个发放间隔就是允许获取的时间
+local newTat = tat + permits * interval
+local allowAt = newTat - tolerance
+-- 如果还没到允许获取的时间，说明令牌不足，请求失败，需要等待到允许获取的时间
+if allowAt - now > epsilon then
+	return {0, math.ceil(allowAt - now), remaining, math.ceil(tat - now)}
+end
+-- 令牌足够，更新理论到达时间，请求成功，令牌发放满之后和不存在时一样，因此可以过期
+newTat = math.floor(newTat * 1000) / 1000
+redis.call("set", KEYS[1], string.format("%.3f", newTat), "px", math.max(1, math.ceil(newTat - now)))
+remaining = math.max(0, math.floor((tolerance - (newTat - now)) / interval + epsilon))
+return {permits, 0, remaining, math.ceil(newTat - now)}
+`
+
+// GCRALimiter 通用信元速率算法（GCRA）限流器，语义同令牌桶，但每个资源只保存一个理论到达时间
+type GCRALimiter struct {
+	capacity   int            // 容量
+	interval   float64        // 发放一个令牌的时间（毫秒）
+	client     redis.Scripter // Redis客户端
+	script     *redis.Script  // TryAcquire脚本
+	clock      limiter.Clock  // 时钟
+	serverTime bool           // 是否使用Redis服务器时间
+}
+
+func NewGCRALimiter(client redis.Scripter, capacity int, rate float64, opts ...Option) *GCRALimiter {
+	o := newOptions(opts)
+	return &GCRALimiter{
+		capacity:   capacity,
+		interval:   float64(time.Second/time.Millisecond) / rate,
+		client:     client,
+		script:     redis.NewScript(gcraLimiterTryAcquireRedisScript),
+		clock:      o.clock,
+		serverTime: o.serverTime,
+	}
+}
+
+// TryAcquire 尝试获取许可
+func (l *GCRALimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过容量
+func (l *GCRALimiter) TryAcquireN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(ctx, resource, n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *GCRALimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *GCRALimiter) WaitN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return err
+	}
+	return limiter.Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(ctx, resource, n)
+	})
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，原子地返回实际获取的许可数量，没有令牌时返回0
+func (l *GCRALimiter) TryAcquireUpTo(ctx context.Context, resource string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	// 当前时间，使用Redis服务器时间时由脚本获取
+	now := scriptNow(l.clock, l.serverTime)
+	result, err := l.script.Run(ctx, l.client, []string{resource}, l.capacity, l.interval, now, n, 1).Int64Slice()
+	if err != nil {
+		return 0, err
+	}
+	return int(result[0]), nil
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过容量
+func (l *GCRALimiter) Allow(ctx context.Context, resource string, n int) (*Result, error) {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return nil, err
+	}
+	return l.acquire(ctx, resource, n)
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *GCRALimiter) tryAcquire(ctx context.Context, resource string, n int) (time.Duration, error) {
+	result, err := l.acquire(ctx, resource, n)
+	if err != nil {
+		return 0, err
+	}
+	// 若请求失败，需要等待到有足够的令牌
+	if !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *GCRALimiter) acquire(ctx context.Context, resource string, n int) (*Result, error) {
+	// 当前时间，使用Redis服务器时间时由脚本获取
+	now := scriptNow(l.clock, l.serverTime)
+	values, err := l.script.Run(ctx, l.client, []string{resource}, l.capacity, l.interval, now, n, 0).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return newResult(l.capacity, values), nil
+}
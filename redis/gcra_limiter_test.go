@@ -0,0 +1,51 @@
+package redis
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
+	"testing"
+	"time"
+)
+
+func TestGCRALimiter(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	// 使用单独的资源，避免和其他测试保存的不同类型的值冲突
+	const resource = "test_gcra"
+	client.Del(context.Background(), resource)
+	t.Cleanup(func() {
+		client.Del(context.Background(), resource)
+	})
+	// 脚本的当前时间精确到毫秒，时钟从整毫秒开始，避免取整之后提前发放令牌
+	clock := limiter.NewManualClock(time.Now().Truncate(time.Millisecond))
+	l := NewGCRALimiter(client, 3, 3, WithClock(clock))
+	steps := []struct {
+		advance time.Duration // 请求之前经过的时间
+		n       int
+		want    bool
+	}{
+		// 初始时令牌是满的
+		{n: 3, want: true},
+		{n: 1, want: false},
+		// 每333.3毫秒发放一个令牌
+		{advance: time.Second / 3, n: 1, want: false},
+		{advance: time.Millisecond, n: 1, want: true},
+		{advance: time.Second * 2 / 3, n: 2, want: true},
+		{n: 1, want: false},
+		// 令牌发放满之后只能获取容量个令牌
+		{advance: time.Hour, n: 3, want: true},
+		{n: 1, want: false},
+	}
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		if got := l.TryAcquireN(context.Background(), resource, step.n) == nil; got != step.want {
+			t.Errorf("TryAcquireN(%d) %d got = %v, want %v", step.n, i, got, step.want)
+		}
+	}
+	// 每个资源只保存一个值
+	if typ := client.Type(context.Background(), resource).Val(); typ != "string" {
+		t.Errorf("Type() = %v, want %v", typ, "string")
+	}
+}
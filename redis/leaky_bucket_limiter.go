@@ -3,17 +3,24 @@ package redis
 import (
 	"context"
 	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
 	"time"
 )
 
-const leakyBucketLimiterTryAcquireRedisScript = `
+const leakyBucketLimiterTryAcquireRedisScript = currentTimeRedisScript + `
 -- ARGV[1]: 最高水位
 -- ARGV[2]: 水流速度/秒
--- ARGV[3]: 当前时间（秒）
+-- ARGV[3]: 当前时间（毫秒），负数时使用Redis服务器时间
+-- ARGV[4]: 是否预留，预留时水位不足也会预支未来的水位
+-- ARGV[5]: 许可数量
+-- 返回获取的许可数量、需要等待的时间、剩余许可数量和水放完需要的时间（毫秒）
+-- 按毫秒连续放水，因此currentLevel可能是小数
 
 local peakLevel = tonumber(ARGV[1])
 local currentVelocity = tonumber(ARGV[2])
-local now = tonumber(ARGV[3])
+local now = currentTime(tonumber(ARGV[3]))
+local reserve = tonumber(ARGV[4]) == 1
+local permits = tonumber(ARGV[5])
 
 local lastTime = tonumber(redis.call("hget", KEYS[1], "lastTime"))
 local currentLevel = tonumber(redis.call("hget", KEYS[1], "currentLevel"))
@@ -28,8 +35,8 @@ end
 -- 距离上次放水的时间
 local interval = now - lastTime
 if interval > 0 then
-	-- 当前水位-距离上次放水的时间(秒)*水流速度
-	local newLevel = currentLevel - interval * currentVelocity
+	-- 当前水位-距离上次放水的时间(毫秒)*水流速度/1000
+	local newLevel = currentLevel - interval * currentVelocity / 1000
 	if newLevel < 0 then 
 		newLevel = 0
 	end 
@@ -37,43 +44,154 @@ if interval > 0 then
 	redis.call("hmset", KEYS[1], "currentLevel", newLevel, "lastTime", now)
 end
 
--- 若到达最高水位，请求失败
-if currentLevel >= peakLevel then
+-- 若超过最高水位，请求失败，需要等待到水位足够低（毫秒）
+if currentLevel + permits > peakLevel and not reserve then
+	local resetAfter = math.ceil(currentLevel * 1000 / currentVelocity)
+	local remaining = math.max(0, math.floor(peakLevel - currentLevel))
+	return {0, math.ceil((currentLevel + permits - peakLevel) * 1000 / currentVelocity), remaining, resetAfter}
+end
+-- 当前水位+许可数量，请求成功，预留时需要等待到水位不超过最高水位（毫秒）
+currentLevel = currentLevel + permits
+redis.call("hset", KEYS[1], "currentLevel", currentLevel)
+-- 水放完之后，状态和初始化时一样，因此可以过期，至少保留1毫秒
+local resetAfter = math.ceil(currentLevel * 1000 / currentVelocity)
+redis.call("pexpire", KEYS[1], math.max(1, resetAfter))
+-- 预留时水位可能超过最高水位
+if currentLevel > peakLevel then
+	return {permits, math.ceil((currentLevel - peakLevel) * 1000 / currentVelocity), 0, resetAfter}
+end
+return {permits, 0, math.floor(peakLevel - currentLevel), resetAfter}
+`
+
+const leakyBucketLimiterCancelRedisScript = `
+-- ARGV[1]: 归还的水位
+
+local level = tonumber(ARGV[1])
+
+local currentLevel = tonumber(redis.call("hget", KEYS[1], "currentLevel"))
+-- 已经过期，说明水已经放完了
+if currentLevel == nil then
 	return 0
 end
--- 若没有到达最高水位，当前水位+1，请求成功
-redis.call("hincrby", KEYS[1], "currentLevel", 1)
-redis.call("expire", KEYS[1], peakLevel / currentVelocity)
+-- 归还水位，但不能低于0
+local newLevel = currentLevel - level
+if newLevel < 0 then
+	newLevel = 0
+end
+redis.call("hset", KEYS[1], "currentLevel", newLevel)
 return 1
 `
 
 // LeakyBucketLimiter 漏桶限流器
 type LeakyBucketLimiter struct {
-	peakLevel       int           // 最高水位
-	currentVelocity int           // 水流速度/秒
-	client          *redis.Client // Redis客户端
-	script          *redis.Script // TryAcquire脚本
+	peakLevel       int            // 最高水位
+	currentVelocity float64        // 水流速度/秒
+	client          redis.Scripter // Redis客户端
+	script          *redis.Script  // TryAcquire脚本
+	cancelScript    *redis.Script  // 取消预留脚本
+	clock           limiter.Clock  // 时钟
+	serverTime      bool           // 是否使用Redis服务器时间
 }
 
-func NewLeakyBucketLimiter(client *redis.Client, peakLevel, currentVelocity int) *LeakyBucketLimiter {
+func NewLeakyBucketLimiter(
+	client redis.Scripter, peakLevel int, currentVelocity float64, opts ...Option) *LeakyBucketLimiter {
+	o := newOptions(opts)
 	return &LeakyBucketLimiter{
 		peakLevel:       peakLevel,
 		currentVelocity: currentVelocity,
 		client:          client,
 		script:          redis.NewScript(leakyBucketLimiterTryAcquireRedisScript),
+		cancelScript:    redis.NewScript(leakyBucketLimiterCancelRedisScript),
+		clock:           o.clock,
+		serverTime:      o.serverTime,
 	}
 }
 
+// TryAcquire 尝试获取许可
 func (l *LeakyBucketLimiter) TryAcquire(ctx context.Context, resource string) error {
-	// 当前时间
-	now := time.Now().Unix()
-	success, err := l.script.Run(ctx, l.client, []string{resource}, l.peakLevel, l.currentVelocity, now).Bool()
-	if err != nil {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过最高水位
+func (l *LeakyBucketLimiter) TryAcquireN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.peakLevel); err != nil {
 		return err
 	}
-	// 若到达窗口请求上限，请求失败
-	if !success {
-		return ErrAcquireFailed
+	_, err := l.tryAcquire(ctx, resource, n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *LeakyBucketLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *LeakyBucketLimiter) WaitN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.peakLevel); err != nil {
+		return err
+	}
+	return limiter.Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(ctx, resource, n)
+	})
+}
+
+// Reserve 预留一个许可，水位不足时预支未来的水位，调用方需要等待Reservation.Delay()之后再执行操作
+// 取消预留会把水位归还到currentLevel
+func (l *LeakyBucketLimiter) Reserve(ctx context.Context, resource string) (*Reservation, error) {
+	return l.ReserveN(ctx, resource, 1)
+}
+
+// ReserveN 预留n个许可，其他同Reserve
+func (l *LeakyBucketLimiter) ReserveN(ctx context.Context, resource string, n int) (*Reservation, error) {
+	// 许可数量不合法或者超过最高水位，永远无法满足
+	if checkPermits(n, l.peakLevel) != nil {
+		return &Reservation{}, nil
+	}
+	// 当前时间，使用Redis服务器时间时由脚本获取
+	now := scriptNow(l.clock, l.serverTime)
+	result, err := l.script.Run(ctx, l.client, []string{resource}, l.peakLevel, l.currentVelocity, now, 1, n).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return &Reservation{
+		ok:        true,
+		timeToAct: l.clock.Now().Add(time.Duration(result[1]) * time.Millisecond),
+		clock:     l.clock,
+		cancel: func(ctx context.Context) error {
+			return l.cancelScript.Run(ctx, l.client, []string{resource}, n).Err()
+		},
+	}, nil
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过最高水位
+func (l *LeakyBucketLimiter) Allow(ctx context.Context, resource string, n int) (*Result, error) {
+	if err := checkPermits(n, l.peakLevel); err != nil {
+		return nil, err
+	}
+	return l.acquire(ctx, resource, n)
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *LeakyBucketLimiter) tryAcquire(ctx context.Context, resource string, n int) (time.Duration, error) {
+	result, err := l.acquire(ctx, resource, n)
+	if err != nil {
+		return 0, err
+	}
+	// 若请求失败，需要等待到水位足够低
+	if !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *LeakyBucketLimiter) acquire(ctx context.Context, resource string, n int) (*Result, error) {
+	// 当前时间，使用Redis服务器时间时由脚本获取
+	now := scriptNow(l.clock, l.serverTime)
+	values, err := l.script.Run(ctx, l.client, []string{resource}, l.peakLevel, l.currentVelocity, now, 0, n).Int64Slice()
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return newResult(l.peakLevel, values), nil
 }
@@ -32,7 +32,7 @@ func TestNewLeakyBucketLimiter(t *testing.T) {
 			client := redis.NewClient(&redis.Options{
 				Addr: "127.0.0.1:6379",
 			})
-			l := NewLeakyBucketLimiter(client, tt.args.peakLevel, tt.args.currentVelocity)
+			l := NewLeakyBucketLimiter(client, tt.args.peakLevel, float64(tt.args.currentVelocity))
 			successCount := 0
 			for i := 0; i < tt.args.peakLevel*2; i++ {
 				if l.TryAcquire(context.Background(), "test") == nil {
@@ -0,0 +1,23 @@
+package redis
+
+import "github.com/jiaxwu/limiter"
+
+var (
+	_ limiter.Limiter = (*FixedWindowLimiter)(nil)
+	_ limiter.Limiter = (*SlidingWindowLimiter)(nil)
+	_ limiter.Limiter = (*SlidingLogLimiter)(nil)
+	_ limiter.Limiter = (*TokenBucketLimiter)(nil)
+	_ limiter.Limiter = (*LeakyBucketLimiter)(nil)
+	_ limiter.Limiter = (*GCRALimiter)(nil)
+	_ limiter.Limiter = (*SlidingWindowCounterLimiter)(nil)
+	_ limiter.Limiter = (*ExactSlidingLogLimiter)(nil)
+	_ limiter.Limiter = (*CalendarWindowLimiter)(nil)
+
+	_ limiter.PartialLimiter = (*FixedWindowLimiter)(nil)
+	_ limiter.PartialLimiter = (*SlidingWindowLimiter)(nil)
+	_ limiter.PartialLimiter = (*TokenBucketLimiter)(nil)
+	_ limiter.PartialLimiter = (*GCRALimiter)(nil)
+	_ limiter.PartialLimiter = (*SlidingWindowCounterLimiter)(nil)
+	_ limiter.PartialLimiter = (*ExactSlidingLogLimiter)(nil)
+	_ limiter.PartialLimiter = (*CalendarWindowLimiter)(nil)
+)
@@ -0,0 +1,162 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
+	"testing"
+	"time"
+)
+
+func TestTryAcquireN(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	fixedWindowLimiter, _ := NewFixedWindowLimiter(client, 10, time.Second)
+	slidingWindowLimiter, _ := NewSlidingWindowLimiter(client, 10, time.Second, time.Second/10)
+	slidingWindowCounterLimiter, _ := NewSlidingWindowCounterLimiter(client, 10, time.Second)
+	exactSlidingLogLimiter, _ := NewExactSlidingLogLimiter(client, 10, time.Second)
+	calendarWindowLimiter, _ := NewCalendarWindowLimiter(client, 10, limiter.CalendarDay, time.UTC)
+	slidingLogLimiter, _ := NewSlidingLogLimiter(client, time.Second/10, []*SlidingLogLimiterStrategy{
+		NewSlidingLogLimiterStrategy(10, time.Second), NewSlidingLogLimiterStrategy(100, time.Minute),
+	})
+	tests := []struct {
+		name    string
+		limiter limiter.Limiter
+	}{
+		{name: "fixed_window", limiter: fixedWindowLimiter},
+		{name: "sliding_window", limiter: slidingWindowLimiter},
+		{name: "sliding_log", limiter: slidingLogLimiter},
+		{name: "token_bucket", limiter: NewTokenBucketLimiter(client, 10, 10)},
+		{name: "leaky_bucket", limiter: NewLeakyBucketLimiter(client, 10, 10)},
+		{name: "gcra", limiter: NewGCRALimiter(client, 10, 10)},
+		{name: "sliding_window_counter", limiter: slidingWindowCounterLimiter},
+		{name: "exact_sliding_log", limiter: exactSlidingLogLimiter},
+		{name: "calendar_window", limiter: calendarWindowLimiter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.Del(context.Background(), "test")
+			steps := []struct {
+				n       int
+				wantErr bool
+			}{
+				{n: 7},
+				// 要么全部获取，要么都不获取
+				{n: 4, wantErr: true},
+				{n: 3},
+				{n: 1, wantErr: true},
+			}
+			for _, step := range steps {
+				err := tt.limiter.TryAcquireN(context.Background(), "test", step.n)
+				if (err != nil) != step.wantErr {
+					t.Errorf("TryAcquireN(%d) error = %v, wantErr %v", step.n, err, step.wantErr)
+				}
+			}
+			if err := tt.limiter.TryAcquireN(context.Background(), "test", 11); !errors.Is(err, ErrPermitsExceedCapacity) {
+				t.Errorf("TryAcquireN(11) error = %v, want %v", err, ErrPermitsExceedCapacity)
+			}
+			if err := tt.limiter.TryAcquireN(context.Background(), "test", 0); !errors.Is(err, ErrInvalidPermits) {
+				t.Errorf("TryAcquireN(0) error = %v, want %v", err, ErrInvalidPermits)
+			}
+		})
+	}
+}
+
+func TestTryAcquireUpTo(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	fixedWindowLimiter, _ := NewFixedWindowLimiter(client, 10, time.Second)
+	slidingWindowLimiter, _ := NewSlidingWindowLimiter(client, 10, time.Second, time.Second/10)
+	slidingWindowCounterLimiter, _ := NewSlidingWindowCounterLimiter(client, 10, time.Second)
+	exactSlidingLogLimiter, _ := NewExactSlidingLogLimiter(client, 10, time.Second)
+	calendarWindowLimiter, _ := NewCalendarWindowLimiter(client, 10, limiter.CalendarDay, time.UTC)
+	tests := []struct {
+		name    string
+		limiter limiter.PartialLimiter
+	}{
+		{name: "fixed_window", limiter: fixedWindowLimiter},
+		{name: "sliding_window", limiter: slidingWindowLimiter},
+		{name: "token_bucket", limiter: NewTokenBucketLimiter(client, 10, 10)},
+		{name: "gcra", limiter: NewGCRALimiter(client, 10, 10)},
+		{name: "sliding_window_counter", limiter: slidingWindowCounterLimiter},
+		{name: "exact_sliding_log", limiter: exactSlidingLogLimiter},
+		{name: "calendar_window", limiter: calendarWindowLimiter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.Del(context.Background(), "test")
+			steps := []struct {
+				n    int
+				want int
+			}{
+				{n: 7, want: 7},
+				// 只获取剩余的许可
+				{n: 7, want: 3},
+				{n: 1, want: 0},
+			}
+			for _, step := range steps {
+				got, err := tt.limiter.TryAcquireUpTo(context.Background(), "test", step.n)
+				if err != nil || got != step.want {
+					t.Errorf("TryAcquireUpTo(%d) = %v, %v, want %v", step.n, got, err, step.want)
+				}
+			}
+			if _, err := tt.limiter.TryAcquireUpTo(context.Background(), "test", 0); !errors.Is(err, ErrInvalidPermits) {
+				t.Errorf("TryAcquireUpTo(0) error = %v, want %v", err, ErrInvalidPermits)
+			}
+		})
+	}
+}
+
+func TestScripter(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	tests := []struct {
+		name   string
+		client redis.Scripter
+	}{
+		{name: "universal_client", client: redis.NewUniversalClient(&redis.UniversalOptions{
+			Addrs: []string{"127.0.0.1:6379"},
+		})},
+		{name: "ring", client: redis.NewRing(&redis.RingOptions{
+			Addrs: map[string]string{"shard": "127.0.0.1:6379"},
+		})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixedWindowLimiter, _ := NewFixedWindowLimiter(tt.client, 1, time.Second)
+			slidingWindowLimiter, _ := NewSlidingWindowLimiter(tt.client, 1, time.Second, time.Second/10)
+			slidingWindowCounterLimiter, _ := NewSlidingWindowCounterLimiter(tt.client, 1, time.Second)
+			exactSlidingLogLimiter, _ := NewExactSlidingLogLimiter(tt.client, 1, time.Second)
+			calendarWindowLimiter, _ := NewCalendarWindowLimiter(tt.client, 1, limiter.CalendarDay, time.UTC)
+			slidingLogLimiter, _ := NewSlidingLogLimiter(tt.client, time.Second/10, []*SlidingLogLimiterStrategy{
+				NewSlidingLogLimiterStrategy(1, time.Second),
+			})
+			limiters := []limiter.Limiter{
+				fixedWindowLimiter,
+				slidingWindowLimiter,
+				slidingLogLimiter,
+				NewTokenBucketLimiter(tt.client, 1, 1),
+				NewLeakyBucketLimiter(tt.client, 1, 1),
+				NewGCRALimiter(tt.client, 1, 1),
+				slidingWindowCounterLimiter,
+				exactSlidingLogLimiter,
+				calendarWindowLimiter,
+			}
+			for i, l := range limiters {
+				resource := fmt.Sprintf("test_%s_%d", tt.name, i)
+				client.Del(context.Background(), resource)
+				if err := l.TryAcquire(context.Background(), resource); err != nil {
+					t.Errorf("limiters[%d].TryAcquire() error = %v, want nil", i, err)
+				}
+				if err := l.TryAcquire(context.Background(), resource); err == nil {
+					t.Errorf("limiters[%d].TryAcquire() error = nil, want error", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package redis
+
+import "github.com/jiaxwu/limiter"
+
+// Option 限流器选项
+type Option func(*options)
+
+type options struct {
+	clock      limiter.Clock // 时钟
+	serverTime bool          // 是否使用Redis服务器时间
+}
+
+// WithClock 设置限流器使用的时钟，用于计算当前时间和等待，默认是limiter.SystemClock
+func WithClock(clock limiter.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// WithServerTime 使用Redis服务器时间计算窗口和令牌，避免多个客户端之间时钟不一致，
+// 此时时钟只用于等待，固定窗口限流器依赖Redis过期时间，总是使用Redis服务器时间，
+// 日历窗口限流器需要时区信息，不支持使用Redis服务器时间
+func WithServerTime() Option {
+	return func(o *options) {
+		o.serverTime = true
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		clock: limiter.SystemClock,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,53 @@
+package redis
+
+import (
+	"context"
+	"github.com/jiaxwu/limiter"
+	"sync"
+	"time"
+)
+
+// Reservation 预留的许可，调用方需要等待Delay()之后再执行操作
+type Reservation struct {
+	ok        bool                            // 是否预留成功
+	timeToAct time.Time                       // 可以执行操作的时间
+	clock     limiter.Clock                   // 时钟
+	cancel    func(ctx context.Context) error // 归还许可
+	mutex     sync.Mutex                      // 避免并发问题
+	canceled  bool                            // 是否已经归还
+}
+
+// OK 是否预留成功，若预留的许可超过了限流器的容量则预留失败
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay 执行操作之前需要等待的时间，预留失败返回0
+func (r *Reservation) Delay() time.Duration {
+	if !r.ok {
+		return 0
+	}
+	delay := r.timeToAct.Sub(r.clock.Now())
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// Cancel 取消预留，归还许可，避免放弃的操作消耗配额
+// 归还成功后多次调用只会归还一次，归还失败可以重试，超过可以执行操作的时间之后，操作可能已经执行，因此不再归还许可
+func (r *Reservation) Cancel(ctx context.Context) error {
+	if !r.ok || r.timeToAct.Before(r.clock.Now()) {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.canceled {
+		return nil
+	}
+	if err := r.cancel(ctx); err != nil {
+		return err
+	}
+	r.canceled = true
+	return nil
+}
@@ -0,0 +1,118 @@
+package redis
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
+	"testing"
+	"time"
+)
+
+func TestReservation(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	tests := []struct {
+		name    string
+		limiter interface {
+			Reserve(ctx context.Context, resource string) (*Reservation, error)
+		}
+		wantDelays []time.Duration // 依次预留的最大等待时间
+	}{
+		{
+			name:       "token_bucket",
+			limiter:    NewTokenBucketLimiter(client, 1, 1),
+			wantDelays: []time.Duration{0, time.Second},
+		},
+		{
+			name:       "leaky_bucket",
+			limiter:    NewLeakyBucketLimiter(client, 1, 1),
+			wantDelays: []time.Duration{0, time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.Del(context.Background(), "test")
+			var r *Reservation
+			var err error
+			for _, want := range tt.wantDelays {
+				r, err = tt.limiter.Reserve(context.Background(), "test")
+				if err != nil {
+					t.Fatalf("Reserve() error = %v", err)
+				}
+				if delay := r.Delay(); !r.OK() || delay > want {
+					t.Errorf("Reserve() delay = %v, want %v", delay, want)
+				}
+			}
+			// 取消后归还许可，再次预留的等待时间和取消前一样
+			if err = r.Cancel(context.Background()); err != nil {
+				t.Fatalf("Cancel() error = %v", err)
+			}
+			r, err = tt.limiter.Reserve(context.Background(), "test")
+			if err != nil {
+				t.Fatalf("Reserve() error = %v", err)
+			}
+			want := tt.wantDelays[len(tt.wantDelays)-1]
+			if r.Delay() > want {
+				t.Errorf("Reserve() after Cancel() delay = %v, want %v", r.Delay(), want)
+			}
+		})
+	}
+}
+
+type reserver interface {
+	Reserve(ctx context.Context, resource string) (*Reservation, error)
+}
+
+func TestReservationCancelAfterAct(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	tests := []struct {
+		name    string
+		limiter func(clock limiter.Clock) reserver
+	}{
+		{
+			name: "token_bucket",
+			limiter: func(clock limiter.Clock) reserver {
+				return NewTokenBucketLimiter(client, 2, 1, WithClock(clock))
+			},
+		},
+		{
+			name: "leaky_bucket",
+			limiter: func(clock limiter.Clock) reserver {
+				return NewLeakyBucketLimiter(client, 1, 1, WithClock(clock))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 一个资源在执行操作之后取消预留，另一个不取消，取消不应该归还许可
+			canceledKey, wantKey := "test_reservation_canceled", "test_reservation_want"
+			client.Del(context.Background(), canceledKey, wantKey)
+			t.Cleanup(func() {
+				client.Del(context.Background(), canceledKey, wantKey)
+			})
+			clock := limiter.NewManualClock(time.Now().Truncate(time.Millisecond))
+			l := tt.limiter(clock)
+			r, err := l.Reserve(context.Background(), canceledKey)
+			if err != nil {
+				t.Fatalf("Reserve() error = %v", err)
+			}
+			if _, err = l.Reserve(context.Background(), wantKey); err != nil {
+				t.Fatalf("Reserve() error = %v", err)
+			}
+			clock.Advance(r.Delay() + time.Millisecond)
+			if err = r.Cancel(context.Background()); err != nil {
+				t.Fatalf("Cancel() error = %v", err)
+			}
+			for i := 0; i < 2; i++ {
+				got, _ := l.Reserve(context.Background(), canceledKey)
+				want, _ := l.Reserve(context.Background(), wantKey)
+				if got.Delay() != want.Delay() {
+					t.Errorf("%d Reserve() after Cancel() delay = %v, want %v", i, got.Delay(), want.Delay())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package redis
+
+import (
+	"github.com/jiaxwu/limiter"
+	"time"
+)
+
+// Result 获取许可的结果
+type Result = limiter.Result
+
+// 把脚本返回的获取的许可数量、需要等待的时间、剩余许可数量和恢复到上限需要等待的时间（毫秒）转换为获取结果
+func newResult(limit int, values []int64) *Result {
+	return &Result{
+		Allowed:    values[0] > 0,
+		Limit:      limit,
+		Remaining:  int(values[2]),
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+		RetryAfter: time.Duration(values[1]) * time.Millisecond,
+	}
+}
@@ -0,0 +1,134 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	clock := limiter.NewManualClock(time.Now().Truncate(time.Second))
+	fixedWindowLimiter, _ := NewFixedWindowLimiter(client, 2, time.Second)
+	slidingWindowLimiter, _ := NewSlidingWindowLimiter(client, 2, time.Second, time.Second/10, WithClock(clock))
+	slidingWindowCounterLimiter, _ := NewSlidingWindowCounterLimiter(client, 2, time.Second, WithClock(clock))
+	exactSlidingLogLimiter, _ := NewExactSlidingLogLimiter(client, 2, time.Second, WithClock(clock))
+	calendarWindowLimiter, _ := NewCalendarWindowLimiter(client, 2, limiter.CalendarMinute, time.UTC,
+		WithClock(limiter.NewManualClock(time.Now().Truncate(time.Minute))))
+	slidingLogLimiter, _ := NewSlidingLogLimiter(client, time.Second/10, []*SlidingLogLimiterStrategy{
+		NewSlidingLogLimiterStrategy(10, time.Minute), NewSlidingLogLimiterStrategy(2, time.Second),
+	}, WithClock(clock))
+	tests := []struct {
+		name    string
+		limiter limiter.Limiter
+		want    []Result
+	}{
+		{
+			name:    "fixed_window",
+			limiter: fixedWindowLimiter,
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second, RetryAfter: time.Second},
+			},
+		},
+		{
+			name:    "sliding_window",
+			limiter: slidingWindowLimiter,
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second, RetryAfter: time.Second},
+			},
+		},
+		{
+			name:    "sliding_log",
+			limiter: slidingLogLimiter,
+			want: []Result{
+				// 结果对应剩余许可最少的策略
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second, RetryAfter: time.Second},
+			},
+		},
+		{
+			name:    "token_bucket",
+			limiter: NewTokenBucketLimiter(client, 2, 2, WithClock(clock)),
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second / 2},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second, RetryAfter: time.Second / 2},
+			},
+		},
+		{
+			name:    "sliding_window_counter",
+			limiter: slidingWindowCounterLimiter,
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second * 2},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second * 2},
+				// 下一个窗口上一个窗口的权重降低到0.5时才能获取
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second * 2, RetryAfter: time.Second + time.Second/2},
+			},
+		},
+		{
+			name:    "exact_sliding_log",
+			limiter: exactSlidingLogLimiter,
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second, RetryAfter: time.Second},
+			},
+		},
+		{
+			name:    "calendar_window",
+			limiter: calendarWindowLimiter,
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Minute},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Minute},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Minute, RetryAfter: time.Minute},
+			},
+		},
+		{
+			name:    "gcra",
+			limiter: NewGCRALimiter(client, 2, 2, WithClock(clock)),
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second / 2},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second, RetryAfter: time.Second / 2},
+			},
+		},
+		{
+			name:    "leaky_bucket",
+			limiter: NewLeakyBucketLimiter(client, 2, 2, WithClock(clock)),
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second / 2},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second, RetryAfter: time.Second / 2},
+			},
+		},
+	}
+	// 固定窗口的时间来自Redis过期时间，允许一定的误差
+	near := func(got, want time.Duration) bool {
+		return got <= want && got > want-100*time.Millisecond
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.Del(context.Background(), "test")
+			for i, want := range tt.want {
+				got, err := tt.limiter.Allow(context.Background(), "test", 1)
+				if err != nil || got.Allowed != want.Allowed || got.Limit != want.Limit || got.Remaining != want.Remaining ||
+					!near(got.ResetAfter, want.ResetAfter) || !near(got.RetryAfter, want.RetryAfter) {
+					t.Errorf("Allow() %d = %+v, %v, want %+v", i, got, err, want)
+				}
+			}
+			if _, err := tt.limiter.Allow(context.Background(), "test", 3); !errors.Is(err, ErrPermitsExceedCapacity) {
+				t.Errorf("Allow(3) error = %v, want %v", err, ErrPermitsExceedCapacity)
+			}
+		})
+	}
+}
@@ -3,24 +3,35 @@ package redis
 import (
 	"context"
 	"errors"
-	"fmt"
 	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
 	"sort"
 	"time"
 )
 
-const slidingLogLimiterTryAcquireRedisScriptHashImpl = `
--- ARGV[1]: 当前小窗口值
--- ARGV[2]: 第一个策略的窗口时间大小
--- ARGV[i * 2 + 1]: 每个策略的起始小窗口值
--- ARGV[i * 2 + 2]: 每个策略的窗口请求上限
+const slidingLogLimiterTryAcquireRedisScriptHashImpl = currentTimeRedisScript + `
+-- ARGV[1]: 当前时间（毫秒），负数时使用Redis服务器时间
+-- ARGV[2]: 小窗口时间大小
+-- ARGV[3]: 许可数量
+-- ARGV[i * 2 + 2]: 每个策略的窗口时间大小
+-- ARGV[i * 2 + 3]: 每个策略的窗口请求上限
+-- 返回违背的策略下标（成功时为-1）、失败时需要等待的时间、结果对应的策略下标、剩余许可数量和所有小窗口过期的时间
+-- 失败时结果对应违背的策略，成功时结果对应剩余许可最少的策略
 
-local currentSmallWindow = tonumber(ARGV[1])
--- 第一个策略的窗口时间大小
-local window = tonumber(ARGV[2])
--- 第一个策略的起始小窗口值
-local startSmallWindow = tonumber(ARGV[3])
-local strategiesLen = #(ARGV) / 2 - 1
+local now = currentTime(tonumber(ARGV[1]))
+local smallWindow = tonumber(ARGV[2])
+local permits = tonumber(ARGV[3])
+-- 当前小窗口值
+local currentSmallWindow = now - now % smallWindow
+local strategiesLen = (#(ARGV) - 3) / 2
+-- 每个策略的起始小窗口值
+local starts = {}
+for j = 1, strategiesLen do
+	starts[j] = currentSmallWindow - tonumber(ARGV[j * 2 + 2]) + smallWindow
+end
+-- 第一个策略的窗口时间大小和起始小窗口值
+local window = tonumber(ARGV[4])
+local startSmallWindow = starts[1]
 
 -- 计算每个策略当前窗口的请求总数
 local counters = redis.call("hgetall", KEYS[1])
@@ -30,43 +41,63 @@ for j = 1, strategiesLen do
 	counts[j] = 0
 end
 
+-- 未过期的小窗口，用于计算需要等待的时间
+local smallWindows = {}
 for i = 1, #(counters) / 2 do 
-	local smallWindow = tonumber(counters[i * 2 - 1])
+	local current = tonumber(counters[i * 2 - 1])
 	local counter = tonumber(counters[i * 2])
-	if smallWindow < startSmallWindow then
-		redis.call("hdel", KEYS[1], smallWindow)
+	if current < startSmallWindow then
+		redis.call("hdel", KEYS[1], current)
 	else 
+		table.insert(smallWindows, {current, counter})
 		for j = 1, strategiesLen do
-			if smallWindow >= tonumber(ARGV[j * 2 + 1]) then
+			if current >= starts[j] then
 				counts[j] = counts[j] + counter
 			end
 		end
 	end
 end
 
--- 若到达对应策略窗口请求上限，请求失败，返回违背的策略下标
+-- 若超过对应策略窗口请求上限，请求失败，返回违背的策略下标和需要等待的时间
 for i = 1, strategiesLen do
-	if counts[i] >= tonumber(ARGV[i * 2 + 2]) then
-		return i - 1
+	local start = starts[i]
+	local strategyWindow = tonumber(ARGV[i * 2 + 2])
+	local limit = tonumber(ARGV[i * 2 + 3])
+	if counts[i] + permits > limit then
+		-- 从最早的小窗口开始过期，直到释放足够的请求
+		table.sort(smallWindows, function(a, b) return a[1] < b[1] end)
+		-- 最新的小窗口过期之后恢复到窗口请求上限
+		local resetAfter = 0
+		if counts[i] > 0 then
+			resetAfter = smallWindows[#(smallWindows)][1] + strategyWindow - now
+		end
+		local need = counts[i] + permits - limit
+		for _, item in ipairs(smallWindows) do
+			if item[1] >= start then
+				need = need - item[2]
+				if need <= 0 then
+					return {i - 1, item[1] + strategyWindow - now, i - 1, limit - counts[i], resetAfter}
+				end
+			end
+		end
+		return {i - 1, currentSmallWindow + strategyWindow - now, i - 1, limit - counts[i], resetAfter}
 	end
 end
 
--- 若没到窗口请求上限，当前小窗口计数器+1，请求成功
-redis.call("hincrby", KEYS[1], currentSmallWindow, 1)
+-- 若没超过窗口请求上限，当前小窗口计数器+许可数量，请求成功
+redis.call("hincrby", KEYS[1], currentSmallWindow, permits)
 redis.call("pexpire", KEYS[1], window)
-return -1
+-- 选择剩余许可最少的策略作为结果
+local index = 1
+for i = 2, strategiesLen do
+	if tonumber(ARGV[i * 2 + 3]) - counts[i] < tonumber(ARGV[index * 2 + 3]) - counts[index] then
+		index = i
+	end
+end
+local remaining = tonumber(ARGV[index * 2 + 3]) - counts[index] - permits
+return {-1, 0, index - 1, remaining, currentSmallWindow + tonumber(ARGV[index * 2 + 2]) - now}
 `
 
-// ViolationStrategyError 违背策略错误
-type ViolationStrategyError struct {
-	Limit  int           // 窗口请求上限
-	Window time.Duration // 窗口时间大小
-}
-
-func (e *ViolationStrategyError) Error() string {
-	return fmt.Sprintf("violation strategy that limit = %d and window = %d", e.Limit, e.Window)
-}
-
 // SlidingLogLimiterStrategy 滑动日志限流器的策略
 type SlidingLogLimiterStrategy struct {
 	limit        int   // 窗口请求上限
@@ -85,11 +116,14 @@ func NewSlidingLogLimiterStrategy(limit int, window time.Duration) *SlidingLogLi
 type SlidingLogLimiter struct {
 	strategies  []*SlidingLogLimiterStrategy // 滑动日志限流器策略列表
 	smallWindow int64                        // 小窗口时间大小
-	client      *redis.Client                // Redis客户端
+	client      redis.Scripter               // Redis客户端
 	script      *redis.Script                // TryAcquire脚本
+	clock       limiter.Clock                // 时钟
+	serverTime  bool                         // 是否使用Redis服务器时间
 }
 
-func NewSlidingLogLimiter(client *redis.Client, smallWindow time.Duration, strategies ...*SlidingLogLimiterStrategy) (
+func NewSlidingLogLimiter(
+	client redis.Scripter, smallWindow time.Duration, strategies []*SlidingLogLimiterStrategy, opts ...Option) (
 	*SlidingLogLimiter, error) {
 	// 复制策略避免被修改
 	strategies = append(make([]*SlidingLogLimiterStrategy, 0, len(strategies)), strategies...)
@@ -134,37 +168,99 @@ func NewSlidingLogLimiter(client *redis.Client, smallWindow time.Duration, strat
 		strategy.smallWindows = strategy.window / int64(smallWindow)
 	}
 
+	o := newOptions(opts)
 	return &SlidingLogLimiter{
 		strategies:  strategies,
 		smallWindow: int64(smallWindow),
 		client:      client,
 		script:      redis.NewScript(slidingLogLimiterTryAcquireRedisScriptHashImpl),
+		clock:       o.clock,
+		serverTime:  o.serverTime,
 	}, nil
 }
 
+// TryAcquire 尝试获取许可
 func (l *SlidingLogLimiter) TryAcquire(ctx context.Context, resource string) error {
-	// 获取当前小窗口值
-	currentSmallWindow := time.Now().UnixMilli() / l.smallWindow * l.smallWindow
-	args := make([]interface{}, len(l.strategies)*2+2)
-	args[0] = currentSmallWindow
-	args[1] = l.strategies[0].window
-	// 获取每个策略的起始小窗口值
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过最小的策略窗口请求上限
+func (l *SlidingLogLimiter) TryAcquireN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.strategies[len(l.strategies)-1].limit); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(ctx, resource, n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *SlidingLogLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *SlidingLogLimiter) WaitN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.strategies[len(l.strategies)-1].limit); err != nil {
+		return err
+	}
+	return limiter.Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(ctx, resource, n)
+	})
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过最小的策略窗口请求上限
+// 获取失败时结果对应违背的策略，获取成功时结果对应剩余许可最少的策略
+func (l *SlidingLogLimiter) Allow(ctx context.Context, resource string, n int) (*Result, error) {
+	if err := checkPermits(n, l.strategies[len(l.strategies)-1].limit); err != nil {
+		return nil, err
+	}
+	result, _, err := l.acquire(ctx, resource, n)
+	return result, err
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *SlidingLogLimiter) tryAcquire(ctx context.Context, resource string, n int) (time.Duration, error) {
+	result, violation, err := l.acquire(ctx, resource, n)
+	if err != nil {
+		return 0, err
+	}
+	// 若到达窗口请求上限，请求失败，返回违背的策略和需要等待的时间
+	if violation != nil {
+		return result.RetryAfter, violation
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果，失败时返回违背的策略
+func (l *SlidingLogLimiter) acquire(ctx context.Context, resource string, n int) (
+	*Result, *ViolationStrategyError, error) {
+	// 当前时间，使用Redis服务器时间时由脚本获取，小窗口值由脚本计算
+	args := make([]interface{}, len(l.strategies)*2+3)
+	args[0] = scriptNow(l.clock, l.serverTime)
+	args[1] = l.smallWindow
+	args[2] = n
 	for i, strategy := range l.strategies {
-		args[i*2+2] = currentSmallWindow - l.smallWindow*(strategy.smallWindows-1)
-		args[i*2+3] = strategy.limit
+		args[i*2+3] = strategy.window
+		args[i*2+4] = strategy.limit
 	}
 
-	index, err := l.script.Run(
-		ctx, l.client, []string{resource}, args...).Int()
+	values, err := l.script.Run(
+		ctx, l.client, []string{resource}, args...).Int64Slice()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	// 若到达窗口请求上限，请求失败
-	if index != -1 {
-		return &ViolationStrategyError{
+	result := &Result{
+		Allowed:    values[0] == -1,
+		Limit:      l.strategies[values[2]].limit,
+		Remaining:  int(values[3]),
+		ResetAfter: time.Duration(values[4]) * time.Millisecond,
+		RetryAfter: time.Duration(values[1]) * time.Millisecond,
+	}
+	if index := values[0]; index != -1 {
+		return result, &ViolationStrategyError{
 			Limit:  l.strategies[index].limit,
-			Window: time.Duration(l.strategies[index].window),
-		}
+			Window: time.Duration(l.strategies[index].window) * time.Millisecond,
+		}, nil
 	}
-	return nil
+	return result, nil, nil
 }
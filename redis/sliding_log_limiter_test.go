@@ -34,7 +34,7 @@ func TestNewSlidingLogLimiter(t *testing.T) {
 			client := redis.NewClient(&redis.Options{
 				Addr: "127.0.0.1:6379",
 			})
-			NewSlidingLogLimiter(client, tt.args.smallWindow, tt.args.strategies...)
+			NewSlidingLogLimiter(client, tt.args.smallWindow, tt.args.strategies)
 		})
 	}
 }
@@ -0,0 +1,183 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
+	"time"
+)
+
+const slidingWindowCounterLimiterTryAcquireRedisScript = currentTimeRedisScript + `
+-- ARGV[1]: 窗口时间大小
+-- ARGV[2]: 窗口请求上限
+-- ARGV[3]: 当前时间（毫秒），负数时使用Redis服务器时间
+-- ARGV[4]: 许可数量
+-- ARGV[5]: 是否部分获取，部分获取时只获取剩余的许可
+-- 返回获取的许可数量、失败时需要等待的时间、剩余许可数量和估算的请求数变成0的时间
+-- 只保存当前窗口的序号、当前窗口计数器和上一个窗口计数器
+
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local now = currentTime(tonumber(ARGV[3]))
+local permits = tonumber(ARGV[4])
+local partial = tonumber(ARGV[5]) == 1
+
+-- 当前窗口的序号
+local current = math.floor(now / window)
+local state = redis.call("hmget", KEYS[1], "current", "counter", "previous")
+local last = tonumber(state[1])
+local counter = tonumber(state[2]) or 0
+local previous = tonumber(state[3]) or 0
+-- 如果进入新的窗口，当前窗口计数器变成上一个窗口计数器，超过一个窗口没有请求时都清0
+-- 时钟回拨时继续使用最后的窗口
+if last == nil then
+	counter = 0
+	previous = 0
+elseif current == last + 1 then
+	previous = counter
+	counter = 0
+elseif current > last + 1 then
+	previous = 0
+	counter = 0
+else
+	current = last
+end
+local elapsed = math.max(0, now - current * window)
+
+-- 剩余的许可数量，窗口请求上限-估算的请求数，先乘后除，整数个请求不会因为浮点数误差变成小数
+local remaining = math.floor(limit - previous * (window - elapsed) / window - counter)
+if partial and permits > remaining and remaining >= 1 then
+	permits = remaining
+end
+
+-- 若估算的请求数超过窗口请求上限，请求失败，需要等待到上一个窗口的权重足够小
+if permits > remaining then
+	local resetAfter = 0
+	if counter > 0 then
+		resetAfter = 2 * window - elapsed
+	elseif previous > 0 then
+		resetAfter = window - elapsed
+	end
+	-- 当前窗口结束之前无法满足，等待到下一个窗口，当前窗口计数器变成上一个窗口计数器
+	local wait = 0
+	if counter + permits > limit then
+		wait = window - elapsed
+		previous = counter
+		counter = 0
+		elapsed = 0
+	end
+	local weight = (limit - counter - permits) / previous
+	wait = wait + math.max(0, math.ceil((1 - weight) * window) - elapsed)
+	return {0, wait, math.max(0, remaining), resetAfter}
+end
+
+-- 若没超过窗口请求上限，当前窗口计数器+许可数量，请求成功，下一个窗口结束之后不再需要当前窗口的计数器
+counter = counter + permits
+redis.call("hmset", KEYS[1], "current", current, "counter", counter, "previous", previous)
+redis.call("pexpire", KEYS[1], 2 * window - elapsed)
+return {permits, 0, math.max(0, remaining - permits), 2 * window - elapsed}
+`
+
+// SlidingWindowCounterLimiter 滑动窗口计数器限流器，每个资源只保存两个计数器，误差范围同limiter.SlidingWindowCounterLimiter
+type SlidingWindowCounterLimiter struct {
+	limit      int            // 窗口请求上限
+	window     int64          // 窗口时间大小
+	client     redis.Scripter // Redis客户端
+	script     *redis.Script  // TryAcquire脚本
+	clock      limiter.Clock  // 时钟
+	serverTime bool           // 是否使用Redis服务器时间
+}
+
+func NewSlidingWindowCounterLimiter(client redis.Scripter, limit int, window time.Duration, opts ...Option) (
+	*SlidingWindowCounterLimiter, error) {
+	// redis过期时间精度最大到毫秒，因此窗口必须能被毫秒整除
+	if window%time.Millisecond != 0 {
+		return nil, errors.New("the window uint must not be less than millisecond")
+	}
+
+	o := newOptions(opts)
+	return &SlidingWindowCounterLimiter{
+		limit:      limit,
+		window:     int64(window / time.Millisecond),
+		client:     client,
+		script:     redis.NewScript(slidingWindowCounterLimiterTryAcquireRedisScript),
+		clock:      o.clock,
+		serverTime: o.serverTime,
+	}, nil
+}
+
+// TryAcquire 尝试获取许可
+func (l *SlidingWindowCounterLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过窗口请求上限
+func (l *SlidingWindowCounterLimiter) TryAcquireN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(ctx, resource, n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *SlidingWindowCounterLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *SlidingWindowCounterLimiter) WaitN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	return limiter.Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(ctx, resource, n)
+	})
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，原子地返回实际获取的许可数量，没有剩余许可时返回0
+func (l *SlidingWindowCounterLimiter) TryAcquireUpTo(ctx context.Context, resource string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	// 当前时间，使用Redis服务器时间时由脚本获取，窗口序号由脚本计算
+	now := scriptNow(l.clock, l.serverTime)
+	result, err := l.script.Run(ctx, l.client, []string{resource}, l.window, l.limit, now, n, 1).Int64Slice()
+	if err != nil {
+		return 0, err
+	}
+	return int(result[0]), nil
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过窗口请求上限
+func (l *SlidingWindowCounterLimiter) Allow(ctx context.Context, resource string, n int) (*Result, error) {
+	if err := checkPermits(n, l.limit); err != nil {
+		return nil, err
+	}
+	return l.acquire(ctx, resource, n)
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *SlidingWindowCounterLimiter) tryAcquire(ctx context.Context, resource string, n int) (time.Duration, error) {
+	result, err := l.acquire(ctx, resource, n)
+	if err != nil {
+		return 0, err
+	}
+	// 若估算的请求数超过窗口请求上限，请求失败，需要等待到上一个窗口的权重足够小
+	if !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *SlidingWindowCounterLimiter) acquire(ctx context.Context, resource string, n int) (*Result, error) {
+	// 当前时间，使用Redis服务器时间时由脚本获取，窗口序号由脚本计算
+	now := scriptNow(l.clock, l.serverTime)
+	values, err := l.script.Run(ctx, l.client, []string{resource}, l.window, l.limit, now, n, 0).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return newResult(l.limit, values), nil
+}
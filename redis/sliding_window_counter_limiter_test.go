@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowCounterLimiter(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	// 使用单独的资源，避免和其他测试保存的不同类型的值冲突
+	const resource = "test_sliding_window_counter"
+	client.Del(context.Background(), resource)
+	t.Cleanup(func() {
+		client.Del(context.Background(), resource)
+	})
+	// 窗口按当前时间/窗口时间大小对齐，时钟从窗口开始
+	clock := limiter.NewManualClock(time.Now().Truncate(time.Second))
+	l, _ := NewSlidingWindowCounterLimiter(client, 10, time.Second, WithClock(clock))
+	steps := []struct {
+		advance time.Duration // 请求之前经过的时间
+		n       int
+		want    Result
+	}{
+		{n: 10, want: Result{Allowed: true, Limit: 10, ResetAfter: time.Second * 2}},
+		// 当前窗口结束之前无法满足，下一个窗口上一个窗口的权重降低到0.9时才能获取
+		{n: 1, want: Result{Limit: 10, ResetAfter: time.Second * 2, RetryAfter: time.Second + time.Second/10}},
+		// 估算的请求数是10*0.9=9
+		{advance: time.Second + time.Second/10, n: 1,
+			want: Result{Allowed: true, Limit: 10, ResetAfter: time.Second*2 - time.Second/10}},
+		// 估算的请求数是10*0.5+1=6
+		{advance: time.Second * 4 / 10, n: 5,
+			want: Result{Limit: 10, Remaining: 4, ResetAfter: time.Second*2 - time.Second/2, RetryAfter: time.Second / 10}},
+		// 超过一个窗口没有请求，两个计数器都清0
+		{advance: time.Second * 2, n: 5, want: Result{Allowed: true, Limit: 10, Remaining: 5, ResetAfter: time.Second*2 - time.Second/2}},
+	}
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		got, err := l.Allow(context.Background(), resource, step.n)
+		if err != nil || *got != step.want {
+			t.Errorf("Allow(%d) %d = %+v, %v, want %+v", step.n, i, got, err, step.want)
+		}
+	}
+	// 进入下一个窗口，当前窗口计数器变成上一个窗口计数器，估算的请求数是5*0.8=4，只能获取6个许可
+	clock.Advance(time.Second * 7 / 10)
+	if got, err := l.TryAcquireUpTo(context.Background(), resource, 10); err != nil || got != 6 {
+		t.Errorf("TryAcquireUpTo(10) = %v, %v, want %v", got, err, 6)
+	}
+	// 估算的请求数是4+6=10，需要等待到上一个窗口的权重降低到0.6
+	want := Result{Limit: 10, ResetAfter: time.Second*2 - time.Second/5, RetryAfter: time.Second / 5}
+	if got, err := l.Allow(context.Background(), resource, 1); err != nil || *got != want {
+		t.Errorf("Allow(1) = %+v, %v, want %+v", got, err, want)
+	}
+	// 下一个窗口结束之后不再需要当前窗口的计数器
+	if ttl := client.PTTL(context.Background(), resource).Val(); ttl <= time.Second*17/10 || ttl > time.Second*18/10 {
+		t.Errorf("PTTL() = %v, want (%v, %v]", ttl, time.Second*17/10, time.Second*18/10)
+	}
+}
@@ -4,19 +4,30 @@ import (
 	"context"
 	"errors"
 	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
 	"time"
 )
 
-const slidingWindowLimiterTryAcquireRedisScriptHashImpl = `
+const slidingWindowLimiterTryAcquireRedisScriptHashImpl = currentTimeRedisScript + `
 -- ARGV[1]: 窗口时间大小
 -- ARGV[2]: 窗口请求上限
--- ARGV[3]: 当前小窗口值
--- ARGV[4]: 起始小窗口值
+-- ARGV[3]: 小窗口时间大小
+-- ARGV[4]: 当前时间（毫秒），负数时使用Redis服务器时间
+-- ARGV[5]: 许可数量
+-- ARGV[6]: 是否部分获取，部分获取时只获取剩余的许可
+-- 返回获取的许可数量、失败时需要等待的时间、剩余许可数量和所有小窗口过期的时间
 
 local window = tonumber(ARGV[1])
 local limit = tonumber(ARGV[2])
-local currentSmallWindow = tonumber(ARGV[3])
-local startSmallWindow = tonumber(ARGV[4])
+local smallWindowSize = tonumber(ARGV[3])
+local now = currentTime(tonumber(ARGV[4]))
+local permits = tonumber(ARGV[5])
+local partial = tonumber(ARGV[6]) == 1
+
+-- 当前小窗口值
+local currentSmallWindow = now - now % smallWindowSize
+-- 起始小窗口值
+local startSmallWindow = currentSmallWindow - window + smallWindowSize
 
 -- 计算当前窗口的请求总数
 local counters = redis.call("hgetall", KEYS[1])
@@ -31,27 +42,60 @@ for i = 1, #(counters) / 2 do
 	end
 end
 
--- 若到达窗口请求上限，请求失败
-if count >= limit then
-	return 0
+if partial and count + permits > limit and count < limit then
+	permits = limit - count
 end
 
--- 若没到窗口请求上限，当前小窗口计数器+1，请求成功
-redis.call("hincrby", KEYS[1], currentSmallWindow, 1)
+-- 若超过窗口请求上限，请求失败，需要等待到足够多的小窗口过期
+if count + permits > limit then
+	local smallWindows = {}
+	for i = 1, #(counters) / 2 do
+		local smallWindow = tonumber(counters[i * 2 - 1])
+		if smallWindow >= startSmallWindow then
+			table.insert(smallWindows, smallWindow)
+		end
+	end
+	table.sort(smallWindows)
+	local resetAfter = 0
+	if #(smallWindows) > 0 then
+		resetAfter = smallWindows[#(smallWindows)] + window - now
+	end
+	local need = count + permits - limit
+	for _, smallWindow in ipairs(smallWindows) do
+		need = need - tonumber(redis.call("hget", KEYS[1], smallWindow))
+		if need <= 0 then
+			return {0, smallWindow + window - now, limit - count, resetAfter}
+		end
+	end
+	return {0, currentSmallWindow + window - now, limit - count, resetAfter}
+end
+
+-- 若没超过窗口请求上限，当前小窗口计数器+许可数量，请求成功
+redis.call("hincrby", KEYS[1], currentSmallWindow, permits)
 redis.call("pexpire", KEYS[1], window)
-return 1
+return {permits, 0, limit - count - permits, currentSmallWindow + window - now}
 `
 
-const slidingWindowLimiterTryAcquireRedisScriptListImpl = `
+const slidingWindowLimiterTryAcquireRedisScriptListImpl = currentTimeRedisScript + `
 -- ARGV[1]: 窗口时间大小
 -- ARGV[2]: 窗口请求上限
--- ARGV[3]: 当前小窗口值
--- ARGV[4]: 起始小窗口值
+-- ARGV[3]: 小窗口时间大小
+-- ARGV[4]: 当前时间（毫秒），负数时使用Redis服务器时间
+-- ARGV[5]: 许可数量
+-- ARGV[6]: 是否部分获取，部分获取时只获取剩余的许可
+-- 返回获取的许可数量、失败时需要等待的时间、剩余许可数量和所有小窗口过期的时间
 
 local window = tonumber(ARGV[1])
 local limit = tonumber(ARGV[2])
-local currentSmallWindow = tonumber(ARGV[3])
-local startSmallWindow = tonumber(ARGV[4])
+local smallWindowSize = tonumber(ARGV[3])
+local now = currentTime(tonumber(ARGV[4]))
+local permits = tonumber(ARGV[5])
+local partial = tonumber(ARGV[6]) == 1
+
+-- 当前小窗口值
+local currentSmallWindow = now - now % smallWindowSize
+-- 起始小窗口值
+local startSmallWindow = currentSmallWindow - window + smallWindowSize
 
 -- 获取list长度
 local len = redis.call("llen", KEYS[1])
@@ -78,9 +122,17 @@ else
 	end
 end
 
--- 若到达窗口请求上限，请求失败
-if counter >= limit then 
-	return 0
+if partial and counter + permits > limit and counter < limit then
+	permits = limit - counter
+end
+
+-- 若超过窗口请求上限，请求失败，需要等待到最早的小窗口过期
+if counter + permits > limit then 
+	if len > 1 then
+		local newest = tonumber(redis.call("lindex", KEYS[1], -2))
+		return {0, tonumber(redis.call("lindex", KEYS[1], 1)) + window - now, limit - counter, newest + window - now}
+	end
+	return {0, currentSmallWindow + window - now, limit - counter, 0}
 end 
 
 -- 如果长度大于1，获取倒数第二第一个元素
@@ -88,36 +140,39 @@ if len > 1 then
 	local smallWindown = tonumber(redis.call("lindex", KEYS[1], -2))
 	-- 如果倒数第二个元素小窗口值大于等于当前小窗口值
 	if smallWindown >= currentSmallWindow then
-		-- 把倒数第二个元素当成当前小窗口（因为它更新），倒数第一个元素值+1
+		-- 把倒数第二个元素当成当前小窗口（因为它更新），倒数第一个元素值+许可数量
 		local countn = redis.call("lindex", KEYS[1], -1)
-		redis.call("lset", KEYS[1], -1, countn + 1)
+		redis.call("lset", KEYS[1], -1, countn + permits)
 	else 
-		-- 否则，添加新的窗口值，添加新的计数（1），更新过期时间
-		redis.call("rpush", KEYS[1], currentSmallWindow, 1)
+		-- 否则，添加新的窗口值，添加新的计数（许可数量），更新过期时间
+		redis.call("rpush", KEYS[1], currentSmallWindow, permits)
 		redis.call("pexpire", KEYS[1], window)
 	end
 else 
-	-- 否则，添加新的窗口值，添加新的计数（1），更新过期时间
-	redis.call("rpush", KEYS[1], currentSmallWindow, 1)
+	-- 否则，添加新的窗口值，添加新的计数（许可数量），更新过期时间
+	redis.call("rpush", KEYS[1], currentSmallWindow, permits)
 	redis.call("pexpire", KEYS[1], window)
 end 
 
--- counter + 1并更新
-redis.call("lset", KEYS[1], 0, counter + 1)
-return 1
+-- counter + 许可数量并更新
+redis.call("lset", KEYS[1], 0, counter + permits)
+-- 最新的小窗口过期之后恢复到窗口请求上限
+local newest = tonumber(redis.call("lindex", KEYS[1], -2))
+return {permits, 0, limit - counter - permits, newest + window - now}
 `
 
 // SlidingWindowLimiter 滑动窗口限流器
 type SlidingWindowLimiter struct {
-	limit        int           // 窗口请求上限
-	window       int64         // 窗口时间大小
-	smallWindow  int64         // 小窗口时间大小
-	smallWindows int64         // 小窗口数量
-	client       *redis.Client // Redis客户端
-	script       *redis.Script // TryAcquire脚本
+	limit       int            // 窗口请求上限
+	window      int64          // 窗口时间大小
+	smallWindow int64          // 小窗口时间大小
+	client      redis.Scripter // Redis客户端
+	script      *redis.Script  // TryAcquire脚本
+	clock       limiter.Clock  // 时钟
+	serverTime  bool           // 是否使用Redis服务器时间
 }
 
-func NewSlidingWindowLimiter(client *redis.Client, limit int, window, smallWindow time.Duration) (
+func NewSlidingWindowLimiter(client redis.Scripter, limit int, window, smallWindow time.Duration, opts ...Option) (
 	*SlidingWindowLimiter, error) {
 	// redis过期时间精度最大到毫秒，因此窗口必须能被毫秒整除
 	if window%time.Millisecond != 0 || smallWindow%time.Millisecond != 0 {
@@ -129,30 +184,91 @@ func NewSlidingWindowLimiter(client *redis.Client, limit int, window, smallWindo
 		return nil, errors.New("window cannot be split by integers")
 	}
 
+	o := newOptions(opts)
 	return &SlidingWindowLimiter{
-		limit:        limit,
-		window:       int64(window / time.Millisecond),
-		smallWindow:  int64(smallWindow / time.Millisecond),
-		smallWindows: int64(window / smallWindow),
-		client:       client,
-		script:       redis.NewScript(slidingWindowLimiterTryAcquireRedisScriptListImpl),
+		limit:       limit,
+		window:      int64(window / time.Millisecond),
+		smallWindow: int64(smallWindow / time.Millisecond),
+		client:      client,
+		script:      redis.NewScript(slidingWindowLimiterTryAcquireRedisScriptListImpl),
+		clock:       o.clock,
+		serverTime:  o.serverTime,
 	}, nil
 }
 
+// TryAcquire 尝试获取许可
 func (l *SlidingWindowLimiter) TryAcquire(ctx context.Context, resource string) error {
-	// 获取当前小窗口值
-	currentSmallWindow := time.Now().UnixMilli() / l.smallWindow * l.smallWindow
-	// 获取起始小窗口值
-	startSmallWindow := currentSmallWindow - l.smallWindow*(l.smallWindows-1)
+	return l.TryAcquireN(ctx, resource, 1)
+}
 
-	success, err := l.script.Run(
-		ctx, l.client, []string{resource}, l.window, l.limit, currentSmallWindow, startSmallWindow).Bool()
-	if err != nil {
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过窗口请求上限
+func (l *SlidingWindowLimiter) TryAcquireN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(ctx, resource, n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *SlidingWindowLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *SlidingWindowLimiter) WaitN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
 		return err
 	}
-	// 若到达窗口请求上限，请求失败
-	if !success {
-		return ErrAcquireFailed
+	return limiter.Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(ctx, resource, n)
+	})
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，原子地返回实际获取的许可数量，没有剩余许可时返回0
+func (l *SlidingWindowLimiter) TryAcquireUpTo(ctx context.Context, resource string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	// 当前时间，使用Redis服务器时间时由脚本获取，小窗口值由脚本计算
+	now := scriptNow(l.clock, l.serverTime)
+	result, err := l.script.Run(
+		ctx, l.client, []string{resource}, l.window, l.limit, l.smallWindow, now, n, 1).Int64Slice()
+	if err != nil {
+		return 0, err
+	}
+	return int(result[0]), nil
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过窗口请求上限
+func (l *SlidingWindowLimiter) Allow(ctx context.Context, resource string, n int) (*Result, error) {
+	if err := checkPermits(n, l.limit); err != nil {
+		return nil, err
+	}
+	return l.acquire(ctx, resource, n)
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *SlidingWindowLimiter) tryAcquire(ctx context.Context, resource string, n int) (time.Duration, error) {
+	result, err := l.acquire(ctx, resource, n)
+	if err != nil {
+		return 0, err
+	}
+	// 若到达窗口请求上限，请求失败，需要等待到足够多的小窗口过期
+	if !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *SlidingWindowLimiter) acquire(ctx context.Context, resource string, n int) (*Result, error) {
+	// 当前时间，使用Redis服务器时间时由脚本获取，小窗口值由脚本计算
+	now := scriptNow(l.clock, l.serverTime)
+	values, err := l.script.Run(
+		ctx, l.client, []string{resource}, l.window, l.limit, l.smallWindow, now, n, 0).Int64Slice()
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return newResult(l.limit, values), nil
 }
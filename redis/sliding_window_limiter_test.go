@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
 	"testing"
 	"time"
 )
@@ -75,3 +76,36 @@ func TestNewSlidingWindowLimiter(t *testing.T) {
 		})
 	}
 }
+
+func TestSlidingWindowLimiterWithManualClock(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	client.Del(context.Background(), "test")
+	clock := limiter.NewManualClock(time.Now().Truncate(time.Second))
+	l, err := NewSlidingWindowLimiter(client, 60, time.Second*5, time.Second, WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewSlidingWindowLimiter() error = %v", err)
+	}
+	acquire := func(n int) int {
+		successCount := 0
+		for i := 0; i < n; i++ {
+			if l.TryAcquire(context.Background(), "test") == nil {
+				successCount++
+			}
+		}
+		return successCount
+	}
+	if got := acquire(30); got != 30 {
+		t.Errorf("TryAcquire() got = %v, want %v", got, 30)
+	}
+	clock.Advance(time.Second * 2)
+	if got := acquire(60); got != 30 {
+		t.Errorf("TryAcquire() got = %v, want %v", got, 30)
+	}
+	// 第一个小窗口过期，释放30个请求
+	clock.Advance(time.Second * 3)
+	if got := acquire(60); got != 30 {
+		t.Errorf("TryAcquire() got = %v, want %v", got, 30)
+	}
+}
@@ -0,0 +1,24 @@
+package redis
+
+import "github.com/jiaxwu/limiter"
+
+// 获取当前时间（毫秒）的脚本函数，需要拼接在脚本开头，传入的当前时间为负数时使用Redis服务器时间
+const currentTimeRedisScript = `
+local function currentTime(now)
+	if now >= 0 then
+		return now
+	end
+	-- 调用TIME之后再执行写命令，需要按命令而不是按脚本复制
+	redis.replicate_commands()
+	local t = redis.call("time")
+	return tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+end
+`
+
+// 传给脚本的当前时间（毫秒），使用Redis服务器时间时返回-1
+func scriptNow(clock limiter.Clock, serverTime bool) int64 {
+	if serverTime {
+		return -1
+	}
+	return clock.Now().UnixMilli()
+}
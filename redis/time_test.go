@@ -0,0 +1,53 @@
+package redis
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
+	"testing"
+	"time"
+)
+
+func TestWithServerTime(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	tests := []struct {
+		name       string
+		newLimiter func(opts ...Option) limiter.Limiter
+	}{
+		{name: "sliding_window", newLimiter: func(opts ...Option) limiter.Limiter {
+			l, _ := NewSlidingWindowLimiter(client, 2, time.Second, time.Second/10, opts...)
+			return l
+		}},
+		{name: "sliding_log", newLimiter: func(opts ...Option) limiter.Limiter {
+			l, _ := NewSlidingLogLimiter(client, time.Second/10, []*SlidingLogLimiterStrategy{
+				NewSlidingLogLimiterStrategy(2, time.Second),
+			}, opts...)
+			return l
+		}},
+		{name: "token_bucket", newLimiter: func(opts ...Option) limiter.Limiter {
+			return NewTokenBucketLimiter(client, 2, 1, opts...)
+		}},
+		{name: "leaky_bucket", newLimiter: func(opts ...Option) limiter.Limiter {
+			return NewLeakyBucketLimiter(client, 2, 1, opts...)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.Del(context.Background(), "test")
+			// 两个客户端的时钟相差一小时，使用服务器时间时应该共享同一个窗口
+			now := time.Now()
+			a := tt.newLimiter(WithServerTime(), WithClock(limiter.NewManualClock(now)))
+			b := tt.newLimiter(WithServerTime(), WithClock(limiter.NewManualClock(now.Add(time.Hour))))
+			for i := 0; i < 2; i++ {
+				if err := a.TryAcquire(context.Background(), "test"); err != nil {
+					t.Fatalf("TryAcquire() %d error = %v", i, err)
+				}
+			}
+			if err := b.TryAcquire(context.Background(), "test"); err == nil {
+				t.Errorf("TryAcquire() with skewed clock error = nil, want error")
+			}
+		})
+	}
+}
@@ -3,17 +3,26 @@ package redis
 import (
 	"context"
 	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
 	"time"
 )
 
-const tokenBucketLimiterTryAcquireRedisScript = `
--- ARGV[1]: 最高水位
--- ARGV[2]: 水流速度/秒
--- ARGV[3]: 当前时间（秒）
+const tokenBucketLimiterTryAcquireRedisScript = currentTimeRedisScript + `
+-- ARGV[1]: 容量
+-- ARGV[2]: 发放令牌速率/秒
+-- ARGV[3]: 当前时间（毫秒），负数时使用Redis服务器时间
+-- ARGV[4]: 是否预留，预留时令牌不足也会预支未来的令牌
+-- ARGV[5]: 许可数量
+-- ARGV[6]: 是否部分获取，部分获取时只获取剩余的令牌
+-- 返回获取的许可数量、需要等待的时间、剩余许可数量和令牌发放满需要的时间（毫秒）
+-- 令牌按毫秒连续发放，因此currentTokens可能是小数
 
 local capacity = tonumber(ARGV[1])
 local rate = tonumber(ARGV[2])
-local now = tonumber(ARGV[3])
+local now = currentTime(tonumber(ARGV[3]))
+local reserve = tonumber(ARGV[4]) == 1
+local permits = tonumber(ARGV[5])
+local partial = tonumber(ARGV[6]) == 1
 
 local lastTime = tonumber(redis.call("hget", KEYS[1], "lastTime"))
 local currentTokens = tonumber(redis.call("hget", KEYS[1], "currentTokens"))
@@ -28,8 +37,8 @@ end
 -- 距离上次发放令牌的时间
 local interval = now - lastTime
 if interval > 0 then
-	-- 当前令牌数量+距离上次发放令牌的时间(秒)*发放令牌速率
-	local newTokens = currentTokens + interval * rate
+	-- 当前令牌数量+距离上次发放令牌的时间(毫秒)*发放令牌速率/1000
+	local newTokens = currentTokens + interval * rate / 1000
 	if newTokens > capacity then 
 		newTokens = capacity
 	end 
@@ -37,43 +46,172 @@ if interval > 0 then
 	redis.call("hmset", KEYS[1], "currentTokens", newTokens, "lastTime", now)
 end
 
--- 如果没有令牌，请求失败
-if currentTokens == 0 then
+if partial and currentTokens < permits and currentTokens >= 1 then
+	permits = math.floor(currentTokens)
+end
+-- 如果令牌不足，请求失败，需要等待到令牌数量足够（毫秒）
+if currentTokens < permits and not reserve then
+	local resetAfter = math.ceil((capacity - currentTokens) * 1000 / rate)
+	local remaining = math.max(0, math.floor(currentTokens))
+	return {0, math.ceil((permits - currentTokens) * 1000 / rate), remaining, resetAfter}
+end
+-- 当前令牌-许可数量，请求成功，预留时需要等待到令牌数量不为负数（毫秒）
+currentTokens = currentTokens - permits
+redis.call("hset", KEYS[1], "currentTokens", currentTokens)
+-- 令牌发放满之后，状态和初始化时一样，因此可以过期，至少保留1毫秒
+local resetAfter = math.ceil((capacity - currentTokens) * 1000 / rate)
+redis.call("pexpire", KEYS[1], math.max(1, resetAfter))
+-- 预留时令牌可能为负数
+if currentTokens < 0 then
+	return {permits, math.ceil(-currentTokens * 1000 / rate), 0, resetAfter}
+end
+return {permits, 0, math.floor(currentTokens), resetAfter}
+`
+
+const tokenBucketLimiterCancelRedisScript = `
+-- ARGV[1]: 容量
+-- ARGV[2]: 归还的令牌数量
+
+local capacity = tonumber(ARGV[1])
+local tokens = tonumber(ARGV[2])
+
+local currentTokens = tonumber(redis.call("hget", KEYS[1], "currentTokens"))
+-- 已经过期，说明令牌已经满了
+if currentTokens == nil then
 	return 0
 end
--- 果有令牌，当前令牌-1，请求成功
-redis.call("hincrby", KEYS[1], "currentTokens", -1)
-redis.call("expire", KEYS[1], capacity / rate)
+-- 归还令牌，但不能超过容量
+local newTokens = currentTokens + tokens
+if newTokens > capacity then
+	newTokens = capacity
+end
+redis.call("hset", KEYS[1], "currentTokens", newTokens)
 return 1
 `
 
 // TokenBucketLimiter 令牌桶限流器
 type TokenBucketLimiter struct {
-	capacity int           // 容量
-	rate     int           // 发放令牌速率/秒
-	client   *redis.Client // Redis客户端
-	script   *redis.Script // TryAcquire脚本
+	capacity     int            // 容量
+	rate         float64        // 发放令牌速率/秒
+	client       redis.Scripter // Redis客户端
+	script       *redis.Script  // TryAcquire脚本
+	cancelScript *redis.Script  // 取消预留脚本
+	clock        limiter.Clock  // 时钟
+	serverTime   bool           // 是否使用Redis服务器时间
 }
 
-func NewTokenBucketLimiter(client *redis.Client, capacity, rate int) *TokenBucketLimiter {
+func NewTokenBucketLimiter(client redis.Scripter, capacity int, rate float64, opts ...Option) *TokenBucketLimiter {
+	o := newOptions(opts)
 	return &TokenBucketLimiter{
-		capacity: capacity,
-		rate:     rate,
-		client:   client,
-		script:   redis.NewScript(tokenBucketLimiterTryAcquireRedisScript),
+		capacity:     capacity,
+		rate:         rate,
+		client:       client,
+		script:       redis.NewScript(tokenBucketLimiterTryAcquireRedisScript),
+		cancelScript: redis.NewScript(tokenBucketLimiterCancelRedisScript),
+		clock:        o.clock,
+		serverTime:   o.serverTime,
 	}
 }
 
+// TryAcquire 尝试获取许可
 func (l *TokenBucketLimiter) TryAcquire(ctx context.Context, resource string) error {
-	// 当前时间
-	now := time.Now().Unix()
-	success, err := l.script.Run(ctx, l.client, []string{resource}, l.capacity, l.rate, now).Bool()
-	if err != nil {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过容量
+func (l *TokenBucketLimiter) TryAcquireN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(ctx, resource, n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *TokenBucketLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *TokenBucketLimiter) WaitN(ctx context.Context, resource string, n int) error {
+	if err := checkPermits(n, l.capacity); err != nil {
 		return err
 	}
-	// 若到达窗口请求上限，请求失败
-	if !success {
-		return ErrAcquireFailed
+	return limiter.Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(ctx, resource, n)
+	})
+}
+
+// Reserve 预留一个许可，令牌不足时预支未来的令牌，调用方需要等待Reservation.Delay()之后再执行操作
+// 取消预留会把令牌归还到currentTokens
+func (l *TokenBucketLimiter) Reserve(ctx context.Context, resource string) (*Reservation, error) {
+	return l.ReserveN(ctx, resource, 1)
+}
+
+// ReserveN 预留n个许可，其他同Reserve
+func (l *TokenBucketLimiter) ReserveN(ctx context.Context, resource string, n int) (*Reservation, error) {
+	// 许可数量不合法或者超过容量，永远无法满足
+	if checkPermits(n, l.capacity) != nil {
+		return &Reservation{}, nil
+	}
+	// 当前时间，使用Redis服务器时间时由脚本获取
+	now := scriptNow(l.clock, l.serverTime)
+	result, err := l.script.Run(ctx, l.client, []string{resource}, l.capacity, l.rate, now, 1, n, 0).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	return &Reservation{
+		ok:        true,
+		timeToAct: l.clock.Now().Add(time.Duration(result[1]) * time.Millisecond),
+		clock:     l.clock,
+		cancel: func(ctx context.Context) error {
+			return l.cancelScript.Run(ctx, l.client, []string{resource}, l.capacity, n).Err()
+		},
+	}, nil
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，原子地返回实际获取的许可数量，没有令牌时返回0
+func (l *TokenBucketLimiter) TryAcquireUpTo(ctx context.Context, resource string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	// 当前时间，使用Redis服务器时间时由脚本获取
+	now := scriptNow(l.clock, l.serverTime)
+	result, err := l.script.Run(ctx, l.client, []string{resource}, l.capacity, l.rate, now, 0, n, 1).Int64Slice()
+	if err != nil {
+		return 0, err
+	}
+	return int(result[0]), nil
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过容量
+func (l *TokenBucketLimiter) Allow(ctx context.Context, resource string, n int) (*Result, error) {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return nil, err
+	}
+	return l.acquire(ctx, resource, n)
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *TokenBucketLimiter) tryAcquire(ctx context.Context, resource string, n int) (time.Duration, error) {
+	result, err := l.acquire(ctx, resource, n)
+	if err != nil {
+		return 0, err
+	}
+	// 若请求失败，需要等待到有足够的令牌
+	if !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *TokenBucketLimiter) acquire(ctx context.Context, resource string, n int) (*Result, error) {
+	// 当前时间，使用Redis服务器时间时由脚本获取
+	now := scriptNow(l.clock, l.serverTime)
+	values, err := l.script.Run(ctx, l.client, []string{resource}, l.capacity, l.rate, now, 0, n, 0).Int64Slice()
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return newResult(l.capacity, values), nil
 }
@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"github.com/go-redis/redis/v8"
+	"github.com/jiaxwu/limiter"
 	"testing"
 	"time"
 )
@@ -31,7 +32,7 @@ func TestNewTokenBucketLimiter(t *testing.T) {
 			client := redis.NewClient(&redis.Options{
 				Addr: "127.0.0.1:6379",
 			})
-			l := NewTokenBucketLimiter(client, tt.args.capacity, tt.args.rate)
+			l := NewTokenBucketLimiter(client, tt.args.capacity, float64(tt.args.rate))
 			successCount := 0
 			for i := 0; i < tt.args.capacity; i++ {
 				if l.TryAcquire(context.Background(), "test") == nil {
@@ -57,3 +58,46 @@ func TestNewTokenBucketLimiter(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenBucketLimiterSubSecondRate(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:6379",
+	})
+	tests := []struct {
+		name     string
+		rate     float64
+		interval time.Duration // 每次请求的间隔
+		want     []bool        // 每次请求是否成功
+	}{
+		{
+			name:     "0.5_per_second",
+			rate:     0.5,
+			interval: time.Second,
+			want:     []bool{true, false, true, false},
+		},
+		{
+			name:     "1000_per_second",
+			rate:     1000,
+			interval: time.Millisecond,
+			want:     []bool{true, true, true, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.Del(context.Background(), "test")
+			clock := limiter.NewManualClock(time.Now())
+			l := NewTokenBucketLimiter(client, 1, tt.rate, WithClock(clock))
+			for i, want := range tt.want {
+				if got := l.TryAcquire(context.Background(), "test") == nil; got != want {
+					t.Errorf("TryAcquire() %d got = %v, want %v", i, got, want)
+				}
+				clock.Advance(tt.interval)
+			}
+			// 过期时间是令牌发放满需要的时间
+			ttl := client.PTTL(context.Background(), "test").Val()
+			if maxTTL := time.Duration(float64(time.Second) / tt.rate); ttl <= 0 || ttl > maxTTL {
+				t.Errorf("PTTL() = %v, want (0, %v]", ttl, maxTTL)
+			}
+		})
+	}
+}
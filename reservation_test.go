@@ -0,0 +1,97 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type reserver interface {
+	Reserve(ctx context.Context, resource string) *Reservation
+}
+
+func TestReservation(t *testing.T) {
+	tests := []struct {
+		name       string
+		limiter    func(clock Clock) reserver
+		wantDelays []time.Duration // 依次预留的等待时间
+	}{
+		{
+			name: "token_bucket",
+			limiter: func(clock Clock) reserver {
+				return NewTokenBucketLimiter(2, 1, WithClock(clock))
+			},
+			wantDelays: []time.Duration{time.Second, time.Second * 2},
+		},
+		{
+			name: "leaky_bucket",
+			limiter: func(clock Clock) reserver {
+				return NewLeakyBucketLimiter(1, 1, WithClock(clock))
+			},
+			wantDelays: []time.Duration{0, time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewManualClock(time.Unix(0, 0))
+			l := tt.limiter(clock)
+			var r *Reservation
+			for _, want := range tt.wantDelays {
+				r = l.Reserve(context.Background(), "test")
+				if !r.OK() || r.Delay() != want {
+					t.Errorf("Reserve() delay = %v, want %v", r.Delay(), want)
+				}
+			}
+			// 取消后归还许可，再次预留的等待时间和取消前一样
+			r.Cancel()
+			r.Cancel()
+			r = l.Reserve(context.Background(), "test")
+			if want := tt.wantDelays[len(tt.wantDelays)-1]; r.Delay() != want {
+				t.Errorf("Reserve() after Cancel() delay = %v, want %v", r.Delay(), want)
+			}
+		})
+	}
+}
+
+func TestReservationCancelAfterAct(t *testing.T) {
+	tests := []struct {
+		name    string
+		limiter func(clock Clock) reserver
+	}{
+		{
+			name: "token_bucket",
+			limiter: func(clock Clock) reserver {
+				return NewTokenBucketLimiter(2, 1, WithClock(clock))
+			},
+		},
+		{
+			name: "leaky_bucket",
+			limiter: func(clock Clock) reserver {
+				return NewLeakyBucketLimiter(1, 1, WithClock(clock))
+			},
+		},
+		{
+			name: "atomic_token_bucket",
+			limiter: func(clock Clock) reserver {
+				return NewAtomicTokenBucketLimiter(2, 1, WithClock(clock))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 一个限流器在执行操作之后取消预留，另一个不取消，取消不应该归还许可
+			clock := NewManualClock(time.Unix(0, 0))
+			canceled, want := tt.limiter(clock), tt.limiter(clock)
+			r := canceled.Reserve(context.Background(), "test")
+			want.Reserve(context.Background(), "test")
+			clock.Advance(r.Delay() + time.Nanosecond)
+			r.Cancel()
+			for i := 0; i < 2; i++ {
+				got, want := canceled.Reserve(context.Background(), "test"), want.Reserve(context.Background(), "test")
+				if got.Delay() != want.Delay() {
+					t.Errorf("%d Reserve() after Cancel() delay = %v, want %v", i, got.Delay(), want.Delay())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package limiter
+
+import "time"
+
+// Result 获取许可的结果，可以用于设置限流响应头和客户端退避
+type Result struct {
+	Allowed    bool          // 是否获取成功
+	Limit      int           // 许可上限
+	Remaining  int           // 剩余许可数量
+	ResetAfter time.Duration // 剩余许可恢复到上限需要等待的时间
+	RetryAfter time.Duration // 获取失败时需要等待的时间，获取成功时为0
+}
@@ -0,0 +1,122 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	slidingWindowLimiter, _ := NewSlidingWindowLimiter(2, time.Second, time.Second/10, WithClock(clock))
+	slidingLogLimiter, _ := NewSlidingLogLimiter(time.Second/10, []*SlidingLogLimiterStrategy{
+		NewSlidingLogLimiterStrategy(10, time.Minute), NewSlidingLogLimiterStrategy(2, time.Second),
+	}, WithClock(clock))
+	tokenBucketLimiter := NewTokenBucketLimiter(2, 2, WithClock(clock))
+	calendarWindowLimiter, _ := NewCalendarWindowLimiter(2, CalendarMinute, time.UTC, WithClock(clock))
+	// 令牌桶初始没有令牌，等待令牌发放
+	clock.Advance(time.Second)
+	tests := []struct {
+		name    string
+		limiter Limiter
+		want    []Result
+	}{
+		{
+			name:    "fixed_window",
+			limiter: NewFixedWindowLimiter(2, time.Second, WithClock(clock)),
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second + 1},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second + 1},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second + 1, RetryAfter: time.Second + 1},
+			},
+		},
+		{
+			name:    "sliding_window",
+			limiter: slidingWindowLimiter,
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second, RetryAfter: time.Second},
+			},
+		},
+		{
+			name:    "sliding_log",
+			limiter: slidingLogLimiter,
+			want: []Result{
+				// 结果对应剩余许可最少的策略
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second, RetryAfter: time.Second},
+			},
+		},
+		{
+			name:    "token_bucket",
+			limiter: tokenBucketLimiter,
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second / 2},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second, RetryAfter: time.Second / 2},
+			},
+		},
+		{
+			name:    "sliding_window_counter",
+			limiter: NewSlidingWindowCounterLimiter(2, time.Second, WithClock(clock)),
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second * 2},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second * 2},
+				// 下一个窗口上一个窗口的权重降低到0.5时才能获取
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second * 2, RetryAfter: time.Second + time.Second/2},
+			},
+		},
+		{
+			name:    "exact_sliding_log",
+			limiter: NewExactSlidingLogLimiter(2, time.Second, WithClock(clock)),
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second, RetryAfter: time.Second},
+			},
+		},
+		{
+			name:    "calendar_window",
+			limiter: calendarWindowLimiter,
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: 59 * time.Second},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: 59 * time.Second},
+				{Limit: 2, Remaining: 0, ResetAfter: 59 * time.Second, RetryAfter: 59 * time.Second},
+			},
+		},
+		{
+			name:    "gcra",
+			limiter: NewGCRALimiter(2, 2, WithClock(clock)),
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second / 2},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second, RetryAfter: time.Second / 2},
+			},
+		},
+		{
+			name:    "leaky_bucket",
+			limiter: NewLeakyBucketLimiter(2, 2, WithClock(clock)),
+			want: []Result{
+				{Allowed: true, Limit: 2, Remaining: 1, ResetAfter: time.Second / 2},
+				{Allowed: true, Limit: 2, Remaining: 0, ResetAfter: time.Second},
+				{Limit: 2, Remaining: 0, ResetAfter: time.Second, RetryAfter: time.Second / 2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, want := range tt.want {
+				got, err := tt.limiter.Allow(context.Background(), "test", 1)
+				if err != nil || *got != want {
+					t.Errorf("Allow() %d = %+v, %v, want %+v", i, got, err, want)
+				}
+			}
+			if _, err := tt.limiter.Allow(context.Background(), "test", 3); !errors.Is(err, ErrPermitsExceedCapacity) {
+				t.Errorf("Allow(3) error = %v, want %v", err, ErrPermitsExceedCapacity)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// ShardedTokenBucketLimiter 分片令牌桶限流器，适用于多核下单个令牌桶竞争激烈的场景
+// 容量和速率按比例拆分到多个无锁令牌桶分片，每个P优先从自己的分片获取，分片令牌不足时从其他分片窃取。
+// 误差范围：
+//  1. 分片容量之和等于容量，速率之和等于速率，因此任意时间段T内获取的许可总数不超过容量+速率*T，不会比单个令牌桶多
+//  2. 每个分片不足一个的小数令牌无法被获取，因此获取失败时所有分片的令牌之和最多还有n+分片数量-1个
+//  3. 窃取失败时会归还已经窃取的令牌，这期间其他请求可能因为令牌被暂时占用而失败
+//  4. 某个分片满了而其他分片没满时，满的分片不再发放令牌，持续有请求时会通过窃取消耗满的分片，因此只影响空闲后的突发
+type ShardedTokenBucketLimiter struct {
+	capacity int                // 容量
+	rate     float64            // 发放令牌速率/秒
+	shards   []tokenBucketShard // 令牌桶分片
+	indexes  sync.Pool          // 当前P优先使用的分片下标，sync.Pool按P缓存，因此同一个P通常拿到同一个下标
+	next     uint32             // 下一个分配的分片下标
+	clock    Clock              // 时钟
+}
+
+// 令牌桶分片，无锁令牌桶放在开头，填充到缓存行大小的整数倍，
+// 因此切片中每个分片的开头都是8字节对齐的，32位平台上也可以原子操作，并且分片之间不会伪共享
+type tokenBucketShard struct {
+	limiter AtomicTokenBucketLimiter                                // 无锁令牌桶
+	_       [64 - unsafe.Sizeof(AtomicTokenBucketLimiter{})%64]byte // 填充缓存行
+}
+
+func NewShardedTokenBucketLimiter(capacity int, rate float64, shards int, opts ...Option) *ShardedTokenBucketLimiter {
+	// 分片数量默认等于P的数量，每个分片至少有一个令牌
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	shards = maxInt(1, minInt(shards, capacity))
+	o := newOptions(opts)
+	l := &ShardedTokenBucketLimiter{
+		capacity: capacity,
+		rate:     rate,
+		shards:   make([]tokenBucketShard, shards),
+		clock:    o.clock,
+	}
+	for i := range l.shards {
+		// 容量平均拆分，余数分给前面的分片，速率按容量比例拆分，因此所有分片发放满需要的时间相同
+		shardCapacity := capacity / shards
+		if i < capacity%shards {
+			shardCapacity++
+		}
+		shardRate := rate
+		if capacity > 0 {
+			shardRate = rate * float64(shardCapacity) / float64(capacity)
+		}
+		l.shards[i].limiter = *NewAtomicTokenBucketLimiter(shardCapacity, shardRate, opts...)
+	}
+	l.indexes.New = func() interface{} {
+		index := int(atomic.AddUint32(&l.next, 1)-1) % len(l.shards)
+		return &index
+	}
+	return l
+}
+
+// TryAcquire 尝试获取许可，内存限流器只保护单个资源，因此忽略resource
+func (l *ShardedTokenBucketLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过容量
+func (l *ShardedTokenBucketLimiter) TryAcquireN(_ context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return err
+	}
+	if !l.take(n) {
+		return ErrAcquireFailed
+	}
+	return nil
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *ShardedTokenBucketLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *ShardedTokenBucketLimiter) WaitN(ctx context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return err
+	}
+	return Wait(ctx, l.clock, func() (time.Duration, error) {
+		if !l.take(n) {
+			return l.result(n).RetryAfter, ErrAcquireFailed
+		}
+		return 0, nil
+	})
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过容量
+// 剩余许可和重置时间需要遍历所有分片，是近似值，只需要判断是否获取成功时应该使用TryAcquireN
+func (l *ShardedTokenBucketLimiter) Allow(_ context.Context, _ string, n int) (*Result, error) {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return nil, err
+	}
+	allowed := l.take(n)
+	result := l.result(0)
+	if !allowed {
+		result = l.result(n)
+	}
+	result.Allowed = allowed
+	return &result, nil
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，返回实际获取的许可数量，没有令牌时返回0
+func (l *ShardedTokenBucketLimiter) TryAcquireUpTo(_ context.Context, _ string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	index := l.indexes.Get().(*int)
+	defer l.indexes.Put(index)
+
+	// 从本地分片开始依次获取，直到获取n个许可
+	granted := 0
+	for i := 0; i < len(l.shards) && granted < n; i++ {
+		shard := &l.shards[(*index+i)%len(l.shards)].limiter
+		got, _ := shard.TryAcquireUpTo(context.Background(), "", n-granted)
+		granted += got
+	}
+	return granted, nil
+}
+
+// 获取n个许可，优先从本地分片获取，不足时从其他分片窃取，返回是否获取成功
+func (l *ShardedTokenBucketLimiter) take(n int) bool {
+	index := l.indexes.Get().(*int)
+	defer l.indexes.Put(index)
+
+	// 本地分片足够时直接获取，不需要访问其他分片
+	local := &l.shards[*index].limiter
+	if local.acquire(n).Allowed {
+		return true
+	}
+	return l.steal(*index, n)
+}
+
+// 从本地分片开始依次窃取，直到窃取n个许可，不足n个时归还已经窃取的令牌
+func (l *ShardedTokenBucketLimiter) steal(index, n int) bool {
+	// 记录每个分片窃取的令牌，用于失败时归还，分片数量不多时使用栈上的数组，避免窃取时分配内存
+	var buf [64]int
+	var grants []int
+	if len(l.shards) <= len(buf) {
+		grants = buf[:len(l.shards)]
+	} else {
+		grants = make([]int, len(l.shards))
+	}
+	granted := 0
+	for i := 0; i < len(l.shards) && granted < n; i++ {
+		j := (index + i) % len(l.shards)
+		grants[j], _ = l.shards[j].limiter.TryAcquireUpTo(context.Background(), "", n-granted)
+		granted += grants[j]
+	}
+	if granted == n {
+		return true
+	}
+	for j, got := range grants {
+		if got > 0 {
+			l.shards[j].limiter.cancel(got)
+		}
+	}
+	return false
+}
+
+// 遍历所有分片计算获取n个许可的结果，剩余许可是所有分片令牌之和，重置时间是最晚发放满的分片的时间
+func (l *ShardedTokenBucketLimiter) result(n int) Result {
+	result := Result{Limit: l.capacity}
+	for i := range l.shards {
+		shard := &l.shards[i].limiter
+		now := shard.now(shard.clock.Now())
+		tokens := now - shard.refill(math.Float64frombits(atomic.LoadUint64(&shard.emptyTime)), now)
+		result.Remaining += maxInt(0, int(math.Floor(tokens)))
+		if resetAfter := shard.waitTokens(tokens, shard.capacity); resetAfter > result.ResetAfter {
+			result.ResetAfter = resetAfter
+		}
+	}
+	// 按总速率估算需要等待的时间，因为小数令牌无法被获取或者被并发请求占用，实际可能需要多等待，Wait会重新尝试
+	if n > 0 {
+		result.RetryAfter = secondsToDuration(float64(maxInt(1, n-result.Remaining)) / l.rate)
+	}
+	return result
+}
@@ -0,0 +1,107 @@
+package limiter
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestTokenBucketShardAlignment(t *testing.T) {
+	// 切片中每个分片的开头都需要8字节对齐，32位平台上才能原子操作
+	if size := unsafe.Sizeof(tokenBucketShard{}); size%64 != 0 {
+		t.Errorf("Sizeof(tokenBucketShard) = %v, want multiple of 64", size)
+	}
+}
+
+func TestShardedTokenBucketLimiter(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewShardedTokenBucketLimiter(100, 100, 8, WithClock(clock))
+	clock.Advance(time.Second)
+	// 从其他分片窃取，因此单个协程也能获取所有令牌
+	for i := 0; i < 10; i++ {
+		if err := l.TryAcquireN(context.Background(), "test", 10); err != nil {
+			t.Fatalf("TryAcquireN(10) %d error = %v", i, err)
+		}
+	}
+	result, _ := l.Allow(context.Background(), "test", 1)
+	want := Result{Limit: 100, ResetAfter: time.Second, RetryAfter: time.Second / 100}
+	if *result != want {
+		t.Errorf("Allow(1) = %+v, want %+v", *result, want)
+	}
+	// 容量为13的分片有6.5个令牌，容量为12的分片有6个令牌，小数令牌无法被获取，因此只能获取48个
+	clock.Advance(time.Second / 2)
+	if err := l.TryAcquireN(context.Background(), "test", 49); err == nil {
+		t.Errorf("TryAcquireN(49) error = nil, want %v", ErrAcquireFailed)
+	}
+	// 窃取失败时归还已经窃取的令牌
+	if got, _ := l.TryAcquireUpTo(context.Background(), "test", 100); got != 48 {
+		t.Errorf("TryAcquireUpTo(100) = %v, want %v", got, 48)
+	}
+}
+
+func TestShardedTokenBucketLimiterErrorBound(t *testing.T) {
+	const (
+		capacity = 100
+		rate     = 1000
+		shards   = 8
+	)
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewShardedTokenBucketLimiter(capacity, rate, shards, WithClock(clock))
+	r := rand.New(rand.NewSource(0))
+	admitted := 0
+	elapsed := time.Duration(0)
+	for i := 0; i < 1000; i++ {
+		d := time.Duration(r.Intn(int(time.Millisecond * 20)))
+		clock.Advance(d)
+		elapsed += d
+		// 多个协程并发获取直到获取失败
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for l.TryAcquire(context.Background(), "test") == nil {
+					mutex.Lock()
+					admitted++
+					mutex.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		// 获取的许可总数不超过容量+速率*时间
+		if upper := capacity + int(elapsed.Seconds()*rate); admitted > upper {
+			t.Fatalf("%d admitted = %v, want <= %v", i, admitted, upper)
+		}
+	}
+	// 持续获取时只有每个分片不足一个的小数令牌无法被获取
+	if lower := int(elapsed.Seconds()*rate) - shards; admitted < lower {
+		t.Errorf("admitted = %v, want >= %v", admitted, lower)
+	}
+}
+
+func BenchmarkShardedTokenBucketLimiter(b *testing.B) {
+	tests := []struct {
+		name    string
+		limiter Limiter
+	}{
+		{name: "atomic", limiter: NewAtomicTokenBucketLimiter(1000, 1e9)},
+		{name: "sharded", limiter: NewShardedTokenBucketLimiter(1000, 1e9, 0)},
+		// 速率很低时分片的令牌很快耗尽，大部分请求需要窃取
+		{name: "atomic_low_rate", limiter: NewAtomicTokenBucketLimiter(1000, 1000)},
+		{name: "sharded_low_rate", limiter: NewShardedTokenBucketLimiter(1000, 1000, 0)},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					tt.limiter.TryAcquire(context.Background(), "test")
+				}
+			})
+		})
+	}
+}
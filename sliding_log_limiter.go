@@ -1,6 +1,7 @@
 package limiter
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sort"
@@ -20,9 +21,8 @@ func (e *ViolationStrategyError) Error() string {
 
 // SlidingLogLimiterStrategy 滑动日志限流器的策略
 type SlidingLogLimiterStrategy struct {
-	limit        int   // 窗口请求上限
-	window       int64 // 窗口时间大小
-	smallWindows int64 // 小窗口数量
+	limit  int   // 窗口请求上限
+	window int64 // 窗口时间大小
 }
 
 func NewSlidingLogLimiterStrategy(limit int, window time.Duration) *SlidingLogLimiterStrategy {
@@ -33,14 +33,32 @@ func NewSlidingLogLimiterStrategy(limit int, window time.Duration) *SlidingLogLi
 }
 
 // SlidingLogLimiter 滑动日志限流器
+// 所有策略共用一个环形数组保存小窗口计数器，每个策略维护自己窗口的请求总数，
+// 因此获取许可的时间只和策略数量有关，和窗口时间大小无关，并且不会分配内存
 type SlidingLogLimiter struct {
-	strategies  []*SlidingLogLimiterStrategy // 滑动日志限流器策略列表
-	smallWindow int64                        // 小窗口时间大小
-	counters    map[int64]int                // 小窗口计数器
-	mutex       sync.Mutex                   // 避免并发问题
+	strategies   []*slidingLogWindow // 策略的窗口，窗口时间大的排前面
+	smallWindow  int64               // 小窗口时间大小
+	smallWindows int64               // 小窗口数量，等于最大的策略窗口的小窗口数量
+	counters     []int               // 小窗口计数器，环形数组，第i个小窗口（当前时间/小窗口时间大小）保存在i%smallWindows
+	current      int64               // 当前小窗口的序号
+	last         int64               // 最后一次获取许可的小窗口序号，用于计算恢复到窗口请求上限的时间
+	clock        Clock               // 时钟
+	mutex        sync.Mutex          // 避免并发问题
 }
 
-func NewSlidingLogLimiter(smallWindow time.Duration, strategies ...*SlidingLogLimiterStrategy) (*SlidingLogLimiter, error) {
+// 策略的窗口
+type slidingLogWindow struct {
+	limit        int                     // 窗口请求上限
+	window       int64                   // 窗口时间大小
+	smallWindows int64                   // 小窗口数量
+	count        int                     // 窗口请求总数
+	scanned      int64                   // 从窗口最早的小窗口开始已经累加的小窗口数量，用于计算需要等待的时间
+	scannedCount int                     // 已经累加的小窗口的请求总数
+	violation    *ViolationStrategyError // 违背策略时返回的错误，提前创建避免分配内存
+}
+
+func NewSlidingLogLimiter(smallWindow time.Duration, strategies []*SlidingLogLimiterStrategy, opts ...Option) (
+	*SlidingLogLimiter, error) {
 	// 复制策略避免被修改
 	strategies = append(make([]*SlidingLogLimiterStrategy, 0, len(strategies)), strategies...)
 
@@ -58,6 +76,7 @@ func NewSlidingLogLimiter(smallWindow time.Duration, strategies ...*SlidingLogLi
 		return a.window > b.window
 	})
 
+	windows := make([]*slidingLogWindow, len(strategies))
 	for i, strategy := range strategies {
 		// 随着窗口时间变小，窗口上限也应该变小
 		if i > 0 {
@@ -69,53 +88,179 @@ func NewSlidingLogLimiter(smallWindow time.Duration, strategies ...*SlidingLogLi
 		if strategy.window%int64(smallWindow) != 0 {
 			return nil, errors.New("window cannot be split by integers")
 		}
-		strategy.smallWindows = strategy.window / int64(smallWindow)
+		windows[i] = &slidingLogWindow{
+			limit:        strategy.limit,
+			window:       strategy.window,
+			smallWindows: strategy.window / int64(smallWindow),
+			violation: &ViolationStrategyError{
+				Limit:  strategy.limit,
+				Window: time.Duration(strategy.window),
+			},
+		}
 	}
 
+	clock := newOptions(opts).clock
 	return &SlidingLogLimiter{
-		strategies:  strategies,
-		smallWindow: int64(smallWindow),
-		counters:    make(map[int64]int),
+		strategies:   windows,
+		smallWindow:  int64(smallWindow),
+		smallWindows: windows[0].smallWindows,
+		counters:     make([]int, windows[0].smallWindows),
+		current:      clock.Now().UnixNano() / int64(smallWindow),
+		clock:        clock,
 	}, nil
 }
 
-func (l *SlidingLogLimiter) TryAcquire() error {
+// TryAcquire 尝试获取许可，内存限流器只保护单个资源，因此忽略resource
+func (l *SlidingLogLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过最小的策略窗口请求上限
+func (l *SlidingLogLimiter) TryAcquireN(_ context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.strategies[len(l.strategies)-1].limit); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *SlidingLogLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *SlidingLogLimiter) WaitN(ctx context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.strategies[len(l.strategies)-1].limit); err != nil {
+		return err
+	}
+	return Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(n)
+	})
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过最小的策略窗口请求上限
+// 获取失败时结果对应违背的策略，获取成功时结果对应剩余许可最少的策略
+func (l *SlidingLogLimiter) Allow(_ context.Context, _ string, n int) (*Result, error) {
+	if err := checkPermits(n, l.strategies[len(l.strategies)-1].limit); err != nil {
+		return nil, err
+	}
+	result, _ := l.acquire(n)
+	return &result, nil
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *SlidingLogLimiter) tryAcquire(n int) (time.Duration, error) {
+	result, violation := l.acquire(n)
+	if violation != nil {
+		return result.RetryAfter, violation
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果，失败时返回违背的策略
+func (l *SlidingLogLimiter) acquire(n int) (Result, *ViolationStrategyError) {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
-	// 获取当前小窗口值
-	currentSmallWindow := time.Now().UnixNano() / l.smallWindow * l.smallWindow
-	// 获取每个策略的起始小窗口值
-	startSmallWindows := make([]int64, len(l.strategies))
-	for i, strategy := range l.strategies {
-		startSmallWindows[i] = currentSmallWindow - l.smallWindow*(strategy.smallWindows-1)
+	// 获取当前时间
+	now := l.clock.Now().UnixNano()
+	l.advance(now)
+
+	// 若超过对应策略窗口请求上限，请求失败，返回违背的策略
+	for _, strategy := range l.strategies {
+		if strategy.count+n > strategy.limit {
+			result := l.result(strategy, now)
+			result.RetryAfter = l.waitSmallWindowsExpire(strategy, strategy.count+n-strategy.limit, now)
+			return result, strategy.violation
+		}
 	}
 
-	// 计算每个策略当前窗口的请求总数
-	counts := make([]int, len(l.strategies))
-	for smallWindow, counter := range l.counters {
-		if smallWindow < startSmallWindows[0] {
-			delete(l.counters, smallWindow)
-			continue
+	// 若没超过窗口请求上限，当前小窗口计数器+n，请求成功
+	l.counters[l.index(l.current)] += n
+	l.last = l.current
+	// 选择剩余许可最少的策略作为结果
+	minStrategy := l.strategies[0]
+	for _, strategy := range l.strategies {
+		strategy.count += n
+		// 已经累加到当前小窗口
+		if strategy.scanned == strategy.smallWindows {
+			strategy.scannedCount += n
 		}
-		for i := range l.strategies {
-			if smallWindow >= startSmallWindows[i] {
-				counts[i] += counter
-			}
+		if strategy.limit-strategy.count < minStrategy.limit-minStrategy.count {
+			minStrategy = strategy
 		}
 	}
+	result := l.result(minStrategy, now)
+	result.Allowed = true
+	return result, nil
+}
 
-	// 若到达对应策略窗口请求上限，请求失败，返回违背的策略
-	for i, strategy := range l.strategies {
-		if counts[i] >= strategy.limit {
-			return &ViolationStrategyError{
-				Limit:  strategy.limit,
-				Window: time.Duration(strategy.window),
+// 计算策略的获取结果，不包括是否成功和需要等待的时间
+func (l *SlidingLogLimiter) result(strategy *slidingLogWindow, now int64) Result {
+	result := Result{
+		Limit:     strategy.limit,
+		Remaining: strategy.limit - strategy.count,
+	}
+	// 最后一次获取许可的小窗口过期之后恢复到窗口请求上限
+	if strategy.count > 0 {
+		result.ResetAfter = time.Duration((l.last+strategy.smallWindows)*l.smallWindow - now)
+	}
+	return result
+}
+
+// 滑动到当前时间所在的小窗口，从每个策略的窗口请求总数中减去过期的小窗口，每个小窗口只会过期一次，因此均摊是O(策略数量)的
+func (l *SlidingLogLimiter) advance(now int64) {
+	current := now / l.smallWindow
+	if current <= l.current {
+		return
+	}
+	// 所有小窗口都过期了
+	if current-l.current >= l.smallWindows {
+		for i := range l.counters {
+			l.counters[i] = 0
+		}
+		for _, strategy := range l.strategies {
+			strategy.count = 0
+			strategy.scanned, strategy.scannedCount = 0, 0
+		}
+		l.current = current
+		return
+	}
+	for l.current < current {
+		l.current++
+		// 每个策略窗口最早的小窗口过期
+		for _, strategy := range l.strategies {
+			counter := l.counters[l.index(l.current-strategy.smallWindows)]
+			strategy.count -= counter
+			if strategy.scanned > 0 {
+				strategy.scanned--
+				strategy.scannedCount -= counter
 			}
 		}
+		// 新的小窗口和最大的策略窗口最早的小窗口在环形数组中的位置相同
+		l.counters[l.index(l.current)] = 0
 	}
+}
+
+// 计算从策略窗口最早的小窗口开始过期，直到释放至少n个请求需要等待的时间，n不能超过策略窗口请求总数
+// 复用上次累加的结果，只有滑动小窗口时才会减少累加的小窗口，因此均摊是O(1)的
+func (l *SlidingLogLimiter) waitSmallWindowsExpire(strategy *slidingLogWindow, n int, now int64) time.Duration {
+	oldest := l.current - strategy.smallWindows + 1
+	for strategy.scannedCount < n && strategy.scanned < strategy.smallWindows {
+		strategy.scannedCount += l.counters[l.index(oldest+strategy.scanned)]
+		strategy.scanned++
+	}
+	// 上次累加的小窗口可能比需要的多
+	for strategy.scanned > 1 && strategy.scannedCount-l.counters[l.index(oldest+strategy.scanned-1)] >= n {
+		strategy.scanned--
+		strategy.scannedCount -= l.counters[l.index(oldest+strategy.scanned)]
+	}
+	// 最后累加的小窗口在策略窗口的起始小窗口超过它时过期
+	return time.Duration((oldest+strategy.scanned-1+strategy.smallWindows)*l.smallWindow - now)
+}
 
-	// 若没到窗口请求上限，当前小窗口计数器+1，请求成功
-	l.counters[currentSmallWindow]++
-	return nil
+// 小窗口在环形数组中的位置
+func (l *SlidingLogLimiter) index(smallWindow int64) int64 {
+	return (smallWindow%l.smallWindows + l.smallWindows) % l.smallWindows
 }
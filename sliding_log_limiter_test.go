@@ -1,6 +1,8 @@
 package limiter
 
 import (
+	"context"
+	"math/rand"
 	"testing"
 	"time"
 )
@@ -30,7 +32,123 @@ func TestNewSlidingLogLimiter(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			NewSlidingLogLimiter(tt.args.smallWindow, tt.args.strategies...)
+			NewSlidingLogLimiter(tt.args.smallWindow, tt.args.strategies)
 		})
 	}
 }
+
+func TestSlidingLogLimiterRingBuffer(t *testing.T) {
+	const smallWindow = time.Millisecond * 100
+	strategies := []struct {
+		limit  int
+		window time.Duration
+	}{
+		// 和限流器内部的顺序一样，窗口时间大的排前面
+		{limit: 1000, window: time.Hour},
+		{limit: 100, window: time.Minute},
+		{limit: 20, window: time.Second},
+	}
+	clock := NewManualClock(time.Unix(0, 0))
+	l, err := NewSlidingLogLimiter(smallWindow, []*SlidingLogLimiterStrategy{
+		NewSlidingLogLimiterStrategy(strategies[2].limit, strategies[2].window),
+		NewSlidingLogLimiterStrategy(strategies[0].limit, strategies[0].window),
+		NewSlidingLogLimiterStrategy(strategies[1].limit, strategies[1].window),
+	}, WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewSlidingLogLimiter() error = %v", err)
+	}
+	// 用逐个遍历小窗口的方式计算期望结果
+	counters := make(map[int64]int)
+	want := func(n int) Result {
+		now := clock.Now().UnixNano()
+		current := now / int64(smallWindow) * int64(smallWindow)
+		// 每个策略窗口内的小窗口计数器和请求总数
+		strategyCounters := make([]map[int64]int, len(strategies))
+		counts := make([]int, len(strategies))
+		for i, strategy := range strategies {
+			start := current - int64(strategy.window) + int64(smallWindow)
+			strategyCounters[i] = make(map[int64]int)
+			for smallWindow, counter := range counters {
+				if smallWindow >= start {
+					strategyCounters[i][smallWindow] = counter
+					counts[i] += counter
+				}
+			}
+		}
+		result := func(i int) Result {
+			result := Result{Limit: strategies[i].limit, Remaining: strategies[i].limit - counts[i]}
+			if counts[i] > 0 {
+				result.ResetAfter = waitSmallWindowsExpire(strategyCounters[i], counts[i], int64(strategies[i].window), now)
+			}
+			return result
+		}
+		for i, strategy := range strategies {
+			if counts[i]+n > strategy.limit {
+				r := result(i)
+				r.RetryAfter = waitSmallWindowsExpire(
+					strategyCounters[i], counts[i]+n-strategy.limit, int64(strategy.window), now)
+				return r
+			}
+		}
+		counters[current] += n
+		index := 0
+		for i, strategy := range strategies {
+			strategyCounters[i][current] += n
+			counts[i] += n
+			if strategy.limit-counts[i] < strategies[index].limit-counts[index] {
+				index = i
+			}
+		}
+		r := result(index)
+		r.Allowed = true
+		return r
+	}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		// 大部分时候时间只前进一点，偶尔跳过一个策略窗口或者所有窗口
+		switch random.Intn(200) {
+		case 0:
+			clock.Advance(time.Hour + time.Duration(random.Int63n(int64(time.Hour))))
+		case 1:
+			clock.Advance(time.Minute)
+		default:
+			clock.Advance(time.Duration(random.Int63n(int64(time.Second))))
+		}
+		n := random.Intn(5) + 1
+		wantResult := want(n)
+		got, err := l.Allow(context.Background(), "test", n)
+		if err != nil || *got != wantResult {
+			t.Fatalf("Allow(%d) %d = %+v, %v, want %+v", n, i, got, err, wantResult)
+		}
+	}
+}
+
+func BenchmarkSlidingLogLimiter(b *testing.B) {
+	// 每次前进一个小窗口，大部分请求成功
+	b.Run("allowed", func(b *testing.B) {
+		clock := NewManualClock(time.Unix(0, 0))
+		l, _ := NewSlidingLogLimiter(time.Millisecond*100, []*SlidingLogLimiterStrategy{
+			NewSlidingLogLimiterStrategy(1<<30, time.Hour),
+			NewSlidingLogLimiterStrategy(1<<20, time.Minute),
+			NewSlidingLogLimiterStrategy(10, time.Second),
+		}, WithClock(clock))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			clock.Advance(time.Millisecond * 100)
+			l.TryAcquire(context.Background(), "test")
+		}
+	})
+	// 时间不变，除了第一个请求都违背策略
+	b.Run("violated", func(b *testing.B) {
+		l, _ := NewSlidingLogLimiter(time.Millisecond*100, []*SlidingLogLimiterStrategy{
+			NewSlidingLogLimiterStrategy(2, time.Hour),
+			NewSlidingLogLimiterStrategy(1, time.Minute),
+		})
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.TryAcquire(context.Background(), "test")
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// SlidingWindowCounterLimiter 滑动窗口计数器限流器，只需要当前窗口和上一个窗口两个计数器
+// 假设上一个窗口的请求均匀分布，滑动窗口内的请求数估算为：上一个窗口计数器*上一个窗口和滑动窗口重叠的比例+当前窗口计数器
+// 误差范围：
+//  1. 请求均匀分布时估算是准确的
+//  2. 上一个窗口的请求集中在窗口末尾时会多放行，但任意窗口时间内实际通过的请求数不超过2倍窗口请求上限
+//  3. 上一个窗口的请求集中在窗口开头时会少放行，需要等待的时间比实际需要的长
+type SlidingWindowCounterLimiter struct {
+	limit    int        // 窗口请求上限
+	window   int64      // 窗口时间大小
+	current  int64      // 当前窗口的序号（当前时间/窗口时间大小）
+	counter  int        // 当前窗口计数器
+	previous int        // 上一个窗口计数器
+	clock    Clock      // 时钟
+	mutex    sync.Mutex // 避免并发问题
+}
+
+func NewSlidingWindowCounterLimiter(limit int, window time.Duration, opts ...Option) *SlidingWindowCounterLimiter {
+	clock := newOptions(opts).clock
+	return &SlidingWindowCounterLimiter{
+		limit:   limit,
+		window:  int64(window),
+		current: clock.Now().UnixNano() / int64(window),
+		clock:   clock,
+	}
+}
+
+// TryAcquire 尝试获取许可，内存限流器只保护单个资源，因此忽略resource
+func (l *SlidingWindowCounterLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过窗口请求上限
+func (l *SlidingWindowCounterLimiter) TryAcquireN(_ context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *SlidingWindowCounterLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *SlidingWindowCounterLimiter) WaitN(ctx context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	return Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(n)
+	})
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过窗口请求上限
+func (l *SlidingWindowCounterLimiter) Allow(_ context.Context, _ string, n int) (*Result, error) {
+	if err := checkPermits(n, l.limit); err != nil {
+		return nil, err
+	}
+	result := l.acquire(n)
+	return &result, nil
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，返回实际获取的许可数量，没有剩余许可时返回0
+func (l *SlidingWindowCounterLimiter) TryAcquireUpTo(_ context.Context, _ string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now().UnixNano()
+	l.advance(now)
+	// 获取剩余的许可，但不超过n
+	granted := maxInt(0, minInt(n, l.remaining(now)))
+	l.counter += granted
+	return granted, nil
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *SlidingWindowCounterLimiter) tryAcquire(n int) (time.Duration, error) {
+	if result := l.acquire(n); !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *SlidingWindowCounterLimiter) acquire(n int) Result {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.clock.Now().UnixNano()
+	l.advance(now)
+	result := Result{Limit: l.limit}
+	// 若估算的请求数超过窗口请求上限，请求失败，需要等待到上一个窗口的权重足够小
+	if l.remaining(now) < n {
+		result.RetryAfter = l.waitRemaining(n, now)
+	} else {
+		// 若没超过窗口请求上限，当前窗口计数器+n，请求成功
+		l.counter += n
+		result.Allowed = true
+	}
+	result.Remaining = maxInt(0, l.remaining(now))
+	// 当前窗口的请求在下一个窗口结束时才不会被估算
+	elapsed := now - l.current*l.window
+	if l.counter > 0 {
+		result.ResetAfter = time.Duration(2*l.window - elapsed)
+	} else if l.previous > 0 {
+		result.ResetAfter = time.Duration(l.window - elapsed)
+	}
+	return result
+}
+
+// 如果进入新的窗口，当前窗口计数器变成上一个窗口计数器，超过一个窗口没有请求时都清0
+func (l *SlidingWindowCounterLimiter) advance(now int64) {
+	current := now / l.window
+	if current <= l.current {
+		return
+	}
+	if current == l.current+1 {
+		l.previous = l.counter
+	} else {
+		l.previous = 0
+	}
+	l.counter = 0
+	l.current = current
+}
+
+// 剩余的许可数量，窗口请求上限-估算的请求数，向下取整，可能为负数
+func (l *SlidingWindowCounterLimiter) remaining(now int64) int {
+	elapsed := now - l.current*l.window
+	// 先乘后除，整数个请求不会因为浮点数误差变成小数
+	estimated := float64(l.previous)*float64(l.window-elapsed)/float64(l.window) + float64(l.counter)
+	return int(math.Floor(float64(l.limit) - estimated))
+}
+
+// 直到剩余的许可数量达到n需要等待的时间
+func (l *SlidingWindowCounterLimiter) waitRemaining(n int, now int64) time.Duration {
+	elapsed := now - l.current*l.window
+	previous, counter, wait := l.previous, l.counter, int64(0)
+	// 当前窗口结束之前无法满足，等待到下一个窗口，当前窗口计数器变成上一个窗口计数器
+	if counter+n > l.limit {
+		previous, counter, wait = counter, 0, l.window-elapsed
+		elapsed = 0
+	}
+	// 上一个窗口的权重需要降低到(窗口请求上限-当前窗口计数器-n)/上一个窗口计数器
+	weight := float64(l.limit-counter-n) / float64(previous)
+	target := int64(math.Ceil((1 - weight) * float64(l.window)))
+	return time.Duration(wait + maxInt64(0, target-elapsed))
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,64 @@
+package limiter
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowCounterLimiter(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewSlidingWindowCounterLimiter(10, time.Second, WithClock(clock))
+	steps := []struct {
+		advance time.Duration // 请求之前经过的时间
+		n       int
+		want    Result
+	}{
+		{n: 10, want: Result{Allowed: true, Limit: 10, ResetAfter: time.Second * 2}},
+		// 当前窗口结束之前无法满足，下一个窗口上一个窗口的权重降低到0.9时才能获取
+		{n: 1, want: Result{Limit: 10, ResetAfter: time.Second * 2, RetryAfter: time.Second + time.Second/10}},
+		// 估算的请求数是10*0.9=9
+		{advance: time.Second + time.Second/10, n: 1,
+			want: Result{Allowed: true, Limit: 10, ResetAfter: time.Second*2 - time.Second/10}},
+		// 估算的请求数是10*0.5+1=6
+		{advance: time.Second * 4 / 10, n: 5,
+			want: Result{Limit: 10, Remaining: 4, ResetAfter: time.Second*2 - time.Second/2, RetryAfter: time.Second / 10}},
+		// 超过一个窗口没有请求，两个计数器都清0
+		{advance: time.Second * 2, n: 5, want: Result{Allowed: true, Limit: 10, Remaining: 5, ResetAfter: time.Second*2 - time.Second/2}},
+	}
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		got, _ := l.Allow(context.Background(), "test", step.n)
+		if *got != step.want {
+			t.Errorf("Allow(%d) %d = %+v, want %+v", step.n, i, *got, step.want)
+		}
+	}
+}
+
+func TestSlidingWindowCounterLimiterErrorBound(t *testing.T) {
+	const limit = 10
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewSlidingWindowCounterLimiter(limit, time.Second, WithClock(clock))
+	r := rand.New(rand.NewSource(0))
+	var admitted []time.Time
+	for i := 0; i < 10000; i++ {
+		clock.Advance(time.Duration(r.Intn(int(time.Second / 10))))
+		n := r.Intn(3) + 1
+		if l.TryAcquireN(context.Background(), "test", n) != nil {
+			continue
+		}
+		now := clock.Now()
+		for j := 0; j < n; j++ {
+			admitted = append(admitted, now)
+		}
+		// 任意窗口时间内实际通过的请求数不超过2倍窗口请求上限
+		count := 0
+		for j := len(admitted) - 1; j >= 0 && now.Sub(admitted[j]) < time.Second; j-- {
+			count++
+		}
+		if count > 2*limit {
+			t.Fatalf("%d count = %v, want <= %v", i, count, 2*limit)
+		}
+	}
+}
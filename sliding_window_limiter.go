@@ -1,60 +1,196 @@
 package limiter
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"time"
 )
 
 // SlidingWindowLimiter 滑动窗口限流器
+// 小窗口计数器保存在环形数组中，并且维护窗口请求总数，因此获取许可是O(1)的，并且不会分配内存
 type SlidingWindowLimiter struct {
-	limit        int           // 窗口请求上限
-	window       int64         // 窗口时间大小
-	smallWindow  int64         // 小窗口时间大小
-	smallWindows int64         // 小窗口数量
-	counters     map[int64]int // 小窗口计数器
-	mutex        sync.Mutex    // 避免并发问题
+	limit        int        // 窗口请求上限
+	window       int64      // 窗口时间大小
+	smallWindow  int64      // 小窗口时间大小
+	smallWindows int64      // 小窗口数量
+	counters     []int      // 小窗口计数器，环形数组，第i个小窗口（当前时间/小窗口时间大小）保存在i%smallWindows
+	current      int64      // 当前小窗口的序号
+	last         int64      // 最后一次获取许可的小窗口序号，用于计算恢复到窗口请求上限的时间
+	count        int        // 窗口请求总数
+	scanned      int64      // 从最早的小窗口开始已经累加的小窗口数量，用于计算需要等待的时间
+	scannedCount int        // 已经累加的小窗口的请求总数
+	clock        Clock      // 时钟
+	mutex        sync.Mutex // 避免并发问题
 }
 
-func NewSlidingWindowLimiter(limit int, window, smallWindow time.Duration) (*SlidingWindowLimiter, error) {
+func NewSlidingWindowLimiter(limit int, window, smallWindow time.Duration, opts ...Option) (
+	*SlidingWindowLimiter, error) {
 	// 窗口时间必须能够被小窗口时间整除
 	if window%smallWindow != 0 {
 		return nil, errors.New("window cannot be split by integers")
 	}
 
+	clock := newOptions(opts).clock
 	return &SlidingWindowLimiter{
 		limit:        limit,
 		window:       int64(window),
 		smallWindow:  int64(smallWindow),
 		smallWindows: int64(window / smallWindow),
-		counters:     make(map[int64]int),
+		counters:     make([]int, window/smallWindow),
+		current:      clock.Now().UnixNano() / int64(smallWindow),
+		clock:        clock,
 	}, nil
 }
 
-func (l *SlidingWindowLimiter) TryAcquire() bool {
+// TryAcquire 尝试获取许可，内存限流器只保护单个资源，因此忽略resource
+func (l *SlidingWindowLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过窗口请求上限
+func (l *SlidingWindowLimiter) TryAcquireN(_ context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *SlidingWindowLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *SlidingWindowLimiter) WaitN(ctx context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.limit); err != nil {
+		return err
+	}
+	return Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(n)
+	})
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过窗口请求上限
+func (l *SlidingWindowLimiter) Allow(_ context.Context, _ string, n int) (*Result, error) {
+	if err := checkPermits(n, l.limit); err != nil {
+		return nil, err
+	}
+	result := l.acquire(n)
+	return &result, nil
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *SlidingWindowLimiter) tryAcquire(n int) (time.Duration, error) {
+	if result := l.acquire(n); !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *SlidingWindowLimiter) acquire(n int) Result {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// 获取当前时间
+	now := l.clock.Now().UnixNano()
+	l.advance(now)
+
+	result := Result{Limit: l.limit}
+	// 若超过窗口请求上限，请求失败，需要等待到足够多的小窗口过期
+	if l.count+n > l.limit {
+		result.RetryAfter = l.waitSmallWindowsExpire(l.count+n-l.limit, now)
+	} else {
+		// 若没超过窗口请求上限，当前小窗口计数器+n，请求成功
+		l.add(n)
+		result.Allowed = true
+	}
+	result.Remaining = l.limit - l.count
+	// 最后一次获取许可的小窗口过期之后恢复到窗口请求上限
+	if l.count > 0 {
+		result.ResetAfter = time.Duration((l.last+l.smallWindows)*l.smallWindow - now)
+	}
+	return result
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，返回实际获取的许可数量，没有剩余许可时返回0
+func (l *SlidingWindowLimiter) TryAcquireUpTo(_ context.Context, _ string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
-	// 获取当前小窗口值
-	currentSmallWindow := time.Now().UnixNano() / l.smallWindow * l.smallWindow
-	// 获取起始小窗口值
-	startSmallWindow := currentSmallWindow - l.smallWindow*(l.smallWindows-1)
-
-	// 计算当前窗口的请求总数
-	var count int
-	for smallWindow, counter := range l.counters {
-		if smallWindow < startSmallWindow {
-			delete(l.counters, smallWindow)
-		} else {
-			count += counter
+	l.advance(l.clock.Now().UnixNano())
+	// 获取剩余的许可，但不超过n
+	granted := minInt(n, l.limit-l.count)
+	if granted <= 0 {
+		return 0, nil
+	}
+	l.add(granted)
+	return granted, nil
+}
+
+// 滑动到当前时间所在的小窗口，清空过期的小窗口，每个小窗口只会被清空一次，因此均摊是O(1)的
+func (l *SlidingWindowLimiter) advance(now int64) {
+	current := now / l.smallWindow
+	if current <= l.current {
+		return
+	}
+	// 所有小窗口都过期了
+	if current-l.current >= l.smallWindows {
+		for i := range l.counters {
+			l.counters[i] = 0
 		}
+		l.current = current
+		l.count = 0
+		l.scanned, l.scannedCount = 0, 0
+		return
 	}
+	for l.current < current {
+		l.current++
+		// 新的小窗口和最早的小窗口在环形数组中的位置相同，清空最早的小窗口
+		i := l.index(l.current)
+		l.count -= l.counters[i]
+		if l.scanned > 0 {
+			l.scanned--
+			l.scannedCount -= l.counters[i]
+		}
+		l.counters[i] = 0
+	}
+}
 
-	// 若到达窗口请求上限，请求失败
-	if count >= l.limit {
-		return false
+// 当前小窗口计数器+n
+func (l *SlidingWindowLimiter) add(n int) {
+	l.counters[l.index(l.current)] += n
+	l.count += n
+	l.last = l.current
+	// 已经累加到当前小窗口
+	if l.scanned == l.smallWindows {
+		l.scannedCount += n
 	}
-	// 若没到窗口请求上限，当前小窗口计数器+1，请求成功
-	l.counters[currentSmallWindow]++
-	return true
+}
+
+// 计算从最早的小窗口开始过期，直到释放至少n个请求需要等待的时间，n不能超过窗口请求总数
+// 复用上次累加的结果，只有滑动小窗口时才会减少累加的小窗口，因此均摊是O(1)的
+func (l *SlidingWindowLimiter) waitSmallWindowsExpire(n int, now int64) time.Duration {
+	oldest := l.current - l.smallWindows + 1
+	for l.scannedCount < n && l.scanned < l.smallWindows {
+		l.scannedCount += l.counters[l.index(oldest+l.scanned)]
+		l.scanned++
+	}
+	// 上次累加的小窗口可能比需要的多
+	for l.scanned > 1 && l.scannedCount-l.counters[l.index(oldest+l.scanned-1)] >= n {
+		l.scanned--
+		l.scannedCount -= l.counters[l.index(oldest+l.scanned)]
+	}
+	// 最后累加的小窗口在起始小窗口超过它时过期
+	return time.Duration((oldest+l.scanned-1+l.smallWindows)*l.smallWindow - now)
+}
+
+// 小窗口在环形数组中的位置
+func (l *SlidingWindowLimiter) index(smallWindow int64) int64 {
+	return (smallWindow%l.smallWindows + l.smallWindows) % l.smallWindows
 }
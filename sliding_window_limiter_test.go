@@ -1,6 +1,9 @@
 package limiter
 
 import (
+	"context"
+	"math/rand"
+	"sort"
 	"testing"
 	"time"
 )
@@ -36,7 +39,7 @@ func TestNewSlidingWindowLimiter(t *testing.T) {
 			}
 			successCount := 0
 			for i := 0; i < tt.args.limit/2; i++ {
-				if l.TryAcquire() {
+				if l.TryAcquire(context.Background(), "test") == nil {
 					successCount++
 				}
 			}
@@ -48,7 +51,7 @@ func TestNewSlidingWindowLimiter(t *testing.T) {
 			time.Sleep(time.Second * 2)
 			successCount = 0
 			for i := 0; i < tt.args.limit-tt.args.limit/2; i++ {
-				if l.TryAcquire() {
+				if l.TryAcquire(context.Background(), "test") == nil {
 					successCount++
 				}
 			}
@@ -59,7 +62,7 @@ func TestNewSlidingWindowLimiter(t *testing.T) {
 			time.Sleep(time.Second * 3)
 			successCount = 0
 			for i := 0; i < tt.args.limit/2; i++ {
-				if l.TryAcquire() {
+				if l.TryAcquire(context.Background(), "test") == nil {
 					successCount++
 				}
 			}
@@ -71,3 +74,88 @@ func TestNewSlidingWindowLimiter(t *testing.T) {
 		})
 	}
 }
+
+func TestSlidingWindowLimiterRingBuffer(t *testing.T) {
+	const (
+		limit       = 1000
+		window      = time.Hour
+		smallWindow = time.Millisecond * 100
+	)
+	clock := NewManualClock(time.Unix(0, 0))
+	l, err := NewSlidingWindowLimiter(limit, window, smallWindow, WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewSlidingWindowLimiter() error = %v", err)
+	}
+	// 用逐个遍历小窗口的方式计算期望结果
+	counters := make(map[int64]int)
+	want := func(n int) Result {
+		now := clock.Now().UnixNano()
+		start := (now/int64(smallWindow) - int64(window/smallWindow) + 1) * int64(smallWindow)
+		count := 0
+		for smallWindow, counter := range counters {
+			if smallWindow < start {
+				delete(counters, smallWindow)
+			} else {
+				count += counter
+			}
+		}
+		result := Result{Limit: limit}
+		if count+n > limit {
+			result.RetryAfter = waitSmallWindowsExpire(counters, count+n-limit, int64(window), now)
+		} else {
+			counters[now/int64(smallWindow)*int64(smallWindow)] += n
+			count += n
+			result.Allowed = true
+		}
+		result.Remaining = limit - count
+		if count > 0 {
+			result.ResetAfter = waitSmallWindowsExpire(counters, count, int64(window), now)
+		}
+		return result
+	}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		// 大部分时候时间只前进一点，偶尔跳过整个窗口
+		switch random.Intn(100) {
+		case 0:
+			clock.Advance(window + time.Duration(random.Int63n(int64(window))))
+		default:
+			clock.Advance(time.Duration(random.Int63n(int64(time.Second))))
+		}
+		n := random.Intn(10) + 1
+		wantResult := want(n)
+		got, err := l.Allow(context.Background(), "test", n)
+		if err != nil || *got != wantResult {
+			t.Fatalf("Allow(%d) %d = %+v, %v, want %+v", n, i, got, err, wantResult)
+		}
+	}
+}
+
+func BenchmarkSlidingWindowLimiter(b *testing.B) {
+	// 1小时的窗口，100毫秒的小窗口，共36000个小窗口
+	l, _ := NewSlidingWindowLimiter(b.N, time.Hour, time.Millisecond*100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.TryAcquire(context.Background(), "test")
+	}
+}
+
+// 计算从最早的小窗口开始过期，直到释放至少n个请求需要等待的时间，逐个遍历小窗口，用于验证环形数组的实现
+func waitSmallWindowsExpire(counters map[int64]int, n int, window, now int64) time.Duration {
+	smallWindows := make([]int64, 0, len(counters))
+	for smallWindow := range counters {
+		smallWindows = append(smallWindows, smallWindow)
+	}
+	sort.Slice(smallWindows, func(i, j int) bool {
+		return smallWindows[i] < smallWindows[j]
+	})
+	for _, smallWindow := range smallWindows {
+		n -= counters[smallWindow]
+		if n <= 0 {
+			// 小窗口在起始小窗口值超过它时过期
+			return time.Duration(smallWindow + window - now)
+		}
+	}
+	return time.Duration(window)
+}
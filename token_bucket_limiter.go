@@ -1,6 +1,8 @@
 package limiter
 
 import (
+	"context"
+	"math"
 	"sync"
 	"time"
 )
@@ -8,41 +10,166 @@ import (
 // TokenBucketLimiter 令牌桶限流器
 type TokenBucketLimiter struct {
 	capacity      int        // 容量
-	currentTokens int        // 令牌数量
-	rate          int        // 发放令牌速率/秒
+	currentTokens float64    // 令牌数量，按时间连续发放，因此可能是小数，预留许可时可能为负数
+	rate          float64    // 发放令牌速率/秒
 	lastTime      time.Time  // 上次发放令牌时间
+	clock         Clock      // 时钟
 	mutex         sync.Mutex // 避免并发问题
 }
 
-func NewTokenBucketLimiter(capacity, rate int) *TokenBucketLimiter {
+func NewTokenBucketLimiter(capacity int, rate float64, opts ...Option) *TokenBucketLimiter {
+	o := newOptions(opts)
 	return &TokenBucketLimiter{
 		capacity: capacity,
 		rate:     rate,
-		lastTime: time.Now(),
+		lastTime: o.clock.Now(),
+		clock:    o.clock,
 	}
 }
 
-func (l *TokenBucketLimiter) TryAcquire() bool {
+// TryAcquire 尝试获取许可，内存限流器只保护单个资源，因此忽略resource
+func (l *TokenBucketLimiter) TryAcquire(ctx context.Context, resource string) error {
+	return l.TryAcquireN(ctx, resource, 1)
+}
+
+// TryAcquireN 尝试获取n个许可，要么全部获取，要么都不获取，n不能超过容量
+func (l *TokenBucketLimiter) TryAcquireN(_ context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return err
+	}
+	_, err := l.tryAcquire(n)
+	return err
+}
+
+// Wait 阻塞直到获取许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *TokenBucketLimiter) Wait(ctx context.Context, resource string) error {
+	return l.WaitN(ctx, resource, 1)
+}
+
+// WaitN 阻塞直到获取n个许可，或者ctx结束，或者预计等待时间超过ctx的截止时间
+func (l *TokenBucketLimiter) WaitN(ctx context.Context, _ string, n int) error {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return err
+	}
+	return Wait(ctx, l.clock, func() (time.Duration, error) {
+		return l.tryAcquire(n)
+	})
+}
+
+// Reserve 预留一个许可，令牌不足时预支未来的令牌，调用方需要等待Reservation.Delay()之后再执行操作
+func (l *TokenBucketLimiter) Reserve(ctx context.Context, resource string) *Reservation {
+	return l.ReserveN(ctx, resource, 1)
+}
+
+// ReserveN 预留n个许可，其他同Reserve
+func (l *TokenBucketLimiter) ReserveN(_ context.Context, _ string, n int) *Reservation {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// 许可数量不合法或者超过容量，永远无法满足
+	if checkPermits(n, l.capacity) != nil {
+		return &Reservation{}
+	}
+	now := l.clock.Now()
+	l.refill(now)
+	// 预支n个令牌
+	l.currentTokens -= float64(n)
+	return &Reservation{
+		ok:        true,
+		timeToAct: now.Add(l.waitTokens(0)),
+		clock:     l.clock,
+		cancel: func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			// 归还令牌，但不能超过容量
+			l.refill(l.clock.Now())
+			l.currentTokens = math.Min(float64(l.capacity), l.currentTokens+float64(n))
+		},
+	}
+}
+
+// Allow 尝试获取n个许可，返回获取结果，n不能超过容量
+func (l *TokenBucketLimiter) Allow(_ context.Context, _ string, n int) (*Result, error) {
+	if err := checkPermits(n, l.capacity); err != nil {
+		return nil, err
+	}
+	result := l.acquire(n)
+	return &result, nil
+}
+
+// 尝试获取n个许可，失败时返回需要等待的时间
+func (l *TokenBucketLimiter) tryAcquire(n int) (time.Duration, error) {
+	if result := l.acquire(n); !result.Allowed {
+		return result.RetryAfter, ErrAcquireFailed
+	}
+	return 0, nil
+}
+
+// 获取n个许可，返回获取结果
+func (l *TokenBucketLimiter) acquire(n int) Result {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
 	// 尝试发放令牌
-	now := time.Now()
+	now := l.clock.Now()
+	l.refill(now)
+
+	result := Result{Limit: l.capacity}
+	// 如果令牌不足，请求失败，需要等待到有足够的令牌
+	if l.currentTokens < float64(n) {
+		result.RetryAfter = l.waitTokens(n)
+	} else {
+		// 如果令牌足够，当前令牌-n，请求成功
+		l.currentTokens -= float64(n)
+		result.Allowed = true
+	}
+	// 预留许可时令牌可能为负数
+	result.Remaining = maxInt(0, int(math.Floor(l.currentTokens)))
+	result.ResetAfter = l.waitTokens(l.capacity)
+	return result
+}
+
+// TryAcquireUpTo 尝试获取最多n个许可，返回实际获取的许可数量，没有令牌时返回0
+func (l *TokenBucketLimiter) TryAcquireUpTo(_ context.Context, _ string, n int) (int, error) {
+	if n < 1 {
+		return 0, ErrInvalidPermits
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.refill(l.clock.Now())
+	// 获取剩余的令牌，但不超过n，预留许可时令牌可能为负数
+	granted := maxInt(0, minInt(n, int(math.Floor(l.currentTokens))))
+	l.currentTokens -= float64(granted)
+	return granted, nil
+}
+
+// 发放令牌
+func (l *TokenBucketLimiter) refill(now time.Time) {
 	// 距离上次发放令牌的时间
 	interval := now.Sub(l.lastTime)
-	if interval >= time.Second {
-		// 当前令牌数量+距离上次发放令牌的时间(秒)*发放令牌速率
-		l.currentTokens = minInt(l.capacity, l.currentTokens+int(interval/time.Second)*l.rate)
+	if interval > 0 {
+		// 当前令牌数量+距离上次发放令牌的时间(秒)*发放令牌速率，不足一秒的部分也会发放
+		l.currentTokens = math.Min(float64(l.capacity), l.currentTokens+interval.Seconds()*l.rate)
 		l.lastTime = now
 	}
+}
+
+// 直到令牌数量达到n需要等待的时间，调用前需要先发放令牌
+func (l *TokenBucketLimiter) waitTokens(n int) time.Duration {
+	if l.currentTokens >= float64(n) {
+		return 0
+	}
+	return secondsToDuration((float64(n) - l.currentTokens) / l.rate)
+}
 
-	// 如果没有令牌，请求失败
-	if l.currentTokens == 0 {
-		return false
+// 把秒数向上取整为时间，速率为0时返回最大时间
+func secondsToDuration(seconds float64) time.Duration {
+	d := math.Ceil(seconds * float64(time.Second))
+	if math.IsNaN(d) || d >= math.MaxInt64 {
+		return math.MaxInt64
 	}
-	// 如果有令牌，当前令牌-1，请求成功
-	l.currentTokens--
-	return true
+	return time.Duration(d)
 }
 
 func minInt(a, b int) int {
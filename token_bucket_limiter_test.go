@@ -1,6 +1,7 @@
 package limiter
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -8,7 +9,7 @@ import (
 func TestNewTokenBucketLimiter(t *testing.T) {
 	type args struct {
 		capacity int
-		rate     int
+		rate     float64
 	}
 	tests := []struct {
 		name string
@@ -26,30 +27,66 @@ func TestNewTokenBucketLimiter(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			l := NewTokenBucketLimiter(tt.args.capacity, tt.args.rate)
-			time.Sleep(time.Second)
+			clock := NewManualClock(time.Unix(0, 0))
+			l := NewTokenBucketLimiter(tt.args.capacity, tt.args.rate, WithClock(clock))
+			clock.Advance(time.Second)
 			successCount := 0
-			for i := 0; i < tt.args.rate; i++ {
-				if l.TryAcquire() {
+			for i := 0; i < int(tt.args.rate); i++ {
+				if l.TryAcquire(context.Background(), "test") == nil {
 					successCount++
 				}
 			}
-			if successCount != tt.args.rate {
+			if successCount != int(tt.args.rate) {
 				t.Errorf("NewTokenBucketLimiter() got = %v, want %v", successCount, tt.args.rate)
 				return
 			}
 
+			// 令牌连续发放，每100ms发放一个令牌，因此除了第一次都能成功
 			successCount = 0
 			for i := 0; i < tt.args.capacity; i++ {
-				if l.TryAcquire() {
+				if l.TryAcquire(context.Background(), "test") == nil {
 					successCount++
 				}
-				time.Sleep(time.Second / 10)
+				clock.Advance(time.Second / 10)
 			}
-			if successCount != tt.args.capacity-tt.args.rate {
-				t.Errorf("NewTokenBucketLimiter() got = %v, want %v", successCount, tt.args.capacity-tt.args.rate)
+			if successCount != tt.args.capacity-1 {
+				t.Errorf("NewTokenBucketLimiter() got = %v, want %v", successCount, tt.args.capacity-1)
 				return
 			}
 		})
 	}
 }
+
+func TestTokenBucketLimiterSubSecondRate(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		interval time.Duration // 每次请求的间隔
+		want     []bool        // 每次请求是否成功
+	}{
+		{
+			name:     "0.5_per_second",
+			rate:     0.5,
+			interval: time.Second,
+			want:     []bool{false, true, false, true},
+		},
+		{
+			name:     "1000_per_second",
+			rate:     1000,
+			interval: time.Millisecond,
+			want:     []bool{true, true, true, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewManualClock(time.Unix(0, 0))
+			l := NewTokenBucketLimiter(1, tt.rate, WithClock(clock))
+			for i, want := range tt.want {
+				clock.Advance(tt.interval)
+				if got := l.TryAcquire(context.Background(), "test") == nil; got != want {
+					t.Errorf("TryAcquire() %d got = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrWaitExceedsDeadline 预计等待时间超过ctx的截止时间
+var ErrWaitExceedsDeadline = errors.New("wait would exceed context deadline")
+
+// Wait 通用的阻塞等待逻辑，使用clock计时，不断调用tryAcquire直到获取成功、ctx结束或者预计等待时间超过ctx的截止时间
+// tryAcquire返回下一次重试前需要等待的时间，以及获取结果
+// 获取结果为ErrAcquireFailed或ViolationStrategyError时会等待后重试，其他错误直接返回
+func Wait(ctx context.Context, clock Clock, tryAcquire func() (time.Duration, error)) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		retryAfter, err := tryAcquire()
+		if err == nil {
+			return nil
+		}
+		var violationStrategyErr *ViolationStrategyError
+		if !errors.Is(err, ErrAcquireFailed) && !errors.As(err, &violationStrategyErr) {
+			return err
+		}
+		// 若预计等待时间超过截止时间，直接返回，ctx的截止时间总是基于系统时间
+		if deadline, ok := ctx.Deadline(); ok && retryAfter > time.Until(deadline) {
+			return ErrWaitExceedsDeadline
+		}
+		timer := clock.NewTimer(retryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package limiter
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		limiter func(clock Clock) Limiter
+		advance time.Duration // 时钟前进的时间
+		timeout time.Duration
+		wantErr error
+	}{
+		{
+			name: "fixed_window",
+			limiter: func(clock Clock) Limiter {
+				return NewFixedWindowLimiter(1, time.Second, WithClock(clock))
+			},
+			advance: time.Second + time.Nanosecond,
+			timeout: time.Minute,
+		},
+		{
+			name: "token_bucket_exceeds_deadline",
+			limiter: func(clock Clock) Limiter {
+				return NewTokenBucketLimiter(1, 1, WithClock(clock))
+			},
+			timeout: time.Second / 10,
+			wantErr: ErrWaitExceedsDeadline,
+		},
+		{
+			name: "token_bucket",
+			limiter: func(clock Clock) Limiter {
+				return NewTokenBucketLimiter(1, 1, WithClock(clock))
+			},
+			advance: time.Second,
+			timeout: time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewManualClock(time.Unix(0, 0))
+			l := tt.limiter(clock)
+			// 先消耗掉可用的许可
+			for l.TryAcquire(context.Background(), "test") == nil {
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			done := make(chan error, 1)
+			go func() {
+				done <- l.Wait(ctx, "test")
+			}()
+			if tt.wantErr == nil {
+				// 等待协程开始等待后再让时间流逝
+				for clock.PendingTimers() == 0 {
+					runtime.Gosched()
+				}
+				clock.Advance(tt.advance)
+			}
+			if err := <-done; err != tt.wantErr {
+				t.Errorf("Wait() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}